@@ -0,0 +1,160 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const DefaultClientRequestTimeout = 60 * time.Second // Default timeout of requests sent to the client
+
+// pendingKey identifies a request sent to the client. Responses are only accepted from the session the request was sent to.
+type pendingKey struct {
+	sessionID string // Stable session ID
+	requestID string
+}
+
+// newClientRequestID returns a random request ID. IDs are limited to 53 bits so clients decoding JSON numbers
+// as floating point values return them unchanged.
+func newClientRequestID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("cannot generate request ID: %w", err)
+	}
+	return strconv.FormatUint(binary.BigEndian.Uint64(b[:])>>11|1, 10), nil
+}
+
+// SendRequest sends a JSON-RPC request to the client of the current session and waits for the response.
+// The result of the response is decoded into result. If the client returns an error, it is returned as *McpClientError.
+//
+// The transport handler must implement McpStreamTransportHandler.
+// The request fails if the client does not respond within ClientRequestTimeout or the context is cancelled.
+func (s *McpServer) SendRequest(ctx context.Context, method string, params any, result any) error {
+	transport, ok := s.TransportHandler.(McpStreamTransportHandler)
+	if !ok {
+		return ErrTransportNotBidirectional
+	}
+
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	timeout := s.ClientRequestTimeout
+	if timeout <= 0 {
+		timeout = DefaultClientRequestTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	id, err := newClientRequestID()
+	if err != nil {
+		return err
+	}
+	key := pendingKey{sessionID: sess.StableSessionID(), requestID: id}
+	ch := make(chan *McpRequest, 1)
+
+	// Register the request before sending it so the response cannot be missed
+	s.pendingMu.Lock()
+	if s.pending == nil {
+		s.pending = make(map[pendingKey]chan *McpRequest)
+	}
+	if _, ok := s.pending[key]; ok {
+		s.pendingMu.Unlock()
+		return fmt.Errorf("duplicate request ID %s", id)
+	}
+	s.pending[key] = ch
+	s.pendingMu.Unlock()
+
+	defer func() {
+		s.pendingMu.Lock()
+		delete(s.pending, key)
+		s.pendingMu.Unlock()
+	}()
+
+	req := McpRequest{
+		JsonRPC: string(s.JsonRPC),
+		ID:      json.Number(id),
+		Method:  method,
+		Params:  params,
+	}
+
+	s.Logf("[%s] Sending request %s to client: %s", sess.StableSessionID(), id, method)
+	if err := transport.SendMessage(ctx, sess.StableSessionID(), req); err != nil {
+		return fmt.Errorf("cannot send request %s: %w", method, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		s.Logf("[%s] Request %s to client timed out: %v", sess.StableSessionID(), id, ctx.Err())
+		return fmt.Errorf("request %s to client: %w", method, ctx.Err())
+	case resp := <-ch:
+		if resp.Error != nil {
+			return &McpClientError{Method: method, Code: resp.Error.Code, Message: resp.Error.Message, Data: resp.Error.Data}
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("cannot decode %s result: %w", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// SendNotification sends a JSON-RPC notification to the client of the session with the given stable session ID.
+// The transport handler must implement McpStreamTransportHandler.
+func (s *McpServer) SendNotification(ctx context.Context, sessionID string, method string, params any) error {
+	transport, ok := s.TransportHandler.(McpStreamTransportHandler)
+	if !ok {
+		return ErrTransportNotBidirectional
+	}
+
+	notification := McpNotification{
+		JsonRPC: string(s.JsonRPC),
+		Method:  method,
+		Params:  params,
+	}
+
+	s.Debugf("[%s] Sending notification to client: %s", sessionID, method)
+	if err := transport.SendMessage(ctx, sessionID, notification); err != nil {
+		return fmt.Errorf("cannot send notification %s: %w", method, err)
+	}
+	return nil
+}
+
+// handleClientResponse delivers a response from the client to the pending request with the same ID
+// sent to the same session. Responses from other sessions are ignored.
+func (s *McpServer) handleClientResponse(sess McpSession, resp *McpRequest) {
+	key := pendingKey{sessionID: sess.StableSessionID(), requestID: resp.ID.String()}
+
+	s.pendingMu.Lock()
+	ch, ok := s.pending[key]
+	delete(s.pending, key)
+	s.pendingMu.Unlock()
+
+	if !ok {
+		s.Logf("[%s] Response %s does not match any pending request of the session", key.sessionID, key.requestID)
+		return
+	}
+	ch <- resp
+}
+
+// deliverClientResponse is the method handling responses of the client. It is wrapped with the middlewares like other methods.
+func (s *McpServer) deliverClientResponse(ctx context.Context, resp *McpRequest) (*McpResponse, error) {
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.handleClientResponse(sess, resp)
+	return &McpResponse{StatusCode: http.StatusAccepted}, nil
+}
+
+// isClientResponse reports whether the message is a response from the client rather than a request or notification.
+func isClientResponse(req *McpRequest) bool {
+	return req.Method == "" && req.ID != "" && (req.Result != nil || req.Error != nil)
+}
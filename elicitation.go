@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// McpElicitAction is the action taken by the user in response to an elicitation request.
+type McpElicitAction string
+
+const McpElicitActionAccept McpElicitAction = "accept"   // User submitted the requested input
+const McpElicitActionDecline McpElicitAction = "decline" // User explicitly declined the request
+const McpElicitActionCancel McpElicitAction = "cancel"   // User dismissed the request without choosing
+
+// McpElicitRequest is the parameters of elicitation/create request.
+// See. https://modelcontextprotocol.io/specification/2025-06-18/client/elicitation
+type McpElicitRequest struct {
+	Message         string             `json:"message"`         // Message shown to the user
+	RequestedSchema McpToolInputSchema `json:"requestedSchema"` // Flat object schema of the requested input
+}
+
+// McpElicitResult is the result of elicitation/create request.
+type McpElicitResult struct {
+	Action  McpElicitAction `json:"action"`            // Action taken by the user
+	Content json.RawMessage `json:"content,omitempty"` // Submitted input. Only set if the action is accept
+}
+
+// Elicit asks the user of the current session for structured input by sending elicitation/create request,
+// and waits for the result. v must be a pointer to a struct; the requested schema is generated from its fields
+// with ElicitSchema. If the user accepts, the submitted input is decoded into v.
+// It can be called from tool functions with the context passed to the tool.
+//
+// It returns ErrElicitationNotSupported if the negotiated protocol version is older than 2025-06-18
+// or the client did not advertise the elicitation capability.
+func Elicit(ctx context.Context, message string, v any) (McpElicitAction, error) {
+	s, err := GetServerFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return "", fmt.Errorf("elicit requires a non-nil pointer to a struct, got %T", v)
+	}
+	schema, err := ElicitSchema(rv.Elem().Interface())
+	if err != nil {
+		return "", err
+	}
+
+	client, err := GetSessionClientFromContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cannot get session client: %w", err)
+	}
+	// Elicitation was added in protocol version 2025-06-18
+	if client.ProtocolVersion < McpProtocol2025_06_18 || client.Capabilities.Elicitation == nil {
+		return "", ErrElicitationNotSupported
+	}
+
+	result := new(McpElicitResult)
+	req := McpElicitRequest{Message: message, RequestedSchema: schema}
+	if err := s.SendRequest(ctx, "elicitation/create", req, result); err != nil {
+		return "", err
+	}
+
+	switch result.Action {
+	case McpElicitActionAccept:
+		if len(result.Content) > 0 {
+			if err := json.Unmarshal(result.Content, v); err != nil {
+				return "", fmt.Errorf("cannot decode elicitation content: %w", err)
+			}
+		}
+	case McpElicitActionDecline, McpElicitActionCancel:
+	default:
+		return "", fmt.Errorf("unknown elicitation action: %s", result.Action)
+	}
+	return result.Action, nil
+}
+
+// ElicitSchema generates the requested schema of an elicitation request from a struct.
+// Elicitation only supports flat objects, so fields must be strings, numbers or booleans.
+//
+// Property names are taken from the json tag and descriptions from the description tag.
+// Fields are required unless they are pointers or tagged with omitempty. Fields tagged with json:"-" are skipped.
+func ElicitSchema(v any) (McpToolInputSchema, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Struct {
+		return McpToolInputSchema{}, fmt.Errorf("elicitation schema requires a struct, got %T", v)
+	}
+
+	schema := McpToolInputSchema{
+		Type:       "object",
+		Properties: make(map[string]McpToolInputSchema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		omitempty := false
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			tagName, opts, _ := strings.Cut(tag, ",")
+			if tagName != "" {
+				name = tagName
+			}
+			omitempty = strings.Contains(","+opts+",", ",omitempty,")
+		}
+
+		fieldType := field.Type
+		optional := omitempty
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+			optional = true
+		}
+
+		var dataType McpToolDataType
+		switch fieldType.Kind() {
+		case reflect.String:
+			dataType = McpToolDataTypeString
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			dataType = McpToolDataTypeNumber
+		case reflect.Bool:
+			dataType = McpToolDataTypeBoolean
+		default:
+			return McpToolInputSchema{}, fmt.Errorf("unsupported elicitation field type: %s (%s)", field.Name, field.Type)
+		}
+
+		schema.Properties[name] = McpToolInputSchema{
+			Type:        string(dataType),
+			Description: field.Tag.Get("description"),
+		}
+		if !optional {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema, nil
+}
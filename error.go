@@ -1,5 +1,13 @@
 package mcp
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
 // Standard JSON-RPC errors
 const ErrInvalidRequestCode = -32600
 const ErrMethodNotFoundCode = -32601
@@ -7,10 +15,18 @@ const ErrInvalidParametersCode = -32602
 const ErrInternalErrorCode = -32603
 const ErrParseErrorCode = -32700
 
+// Server-defined errors
+const ErrLimitExceededCode = -32000 // Concurrency or rate limit exceeded
+
+const DefaultLimitRetryAfter = time.Second // Suggested retry delay when a concurrency limit is exceeded
+
 type McpError struct {
 	Code    int    `json:"code"`           // Error code
 	Message string `json:"message"`        // Error message
 	Data    any    `json:"data,omitempty"` // Error data
+
+	StatusCode int           `json:"-"` // Status code hint for the transport layer (e.g. HTTP status code). Zero uses the transport default
+	RetryAfter time.Duration `json:"-"` // Retry delay hint for the transport layer (e.g. Retry-After HTTP header). Zero if not set
 }
 
 func (e McpError) Error() string {
@@ -25,15 +41,58 @@ func NewMcpError(code int, message string, data any) *McpError {
 	}
 }
 
-var ErrNoSessionHeader = NewMcpError(ErrInvalidRequestCode, "no session header", nil)
+// WithStatusCode returns a copy of the error with the given transport status code hint.
+func (e *McpError) WithStatusCode(statusCode int) *McpError {
+	err := *e
+	err.StatusCode = statusCode
+	return &err
+}
+
+var ErrInvalidRequest = NewMcpError(ErrInvalidRequestCode, "invalid request", nil).WithStatusCode(http.StatusBadRequest)
+
+var ErrNoSessionHeader = NewMcpError(ErrInvalidRequestCode, "no session header", nil).WithStatusCode(http.StatusBadRequest)
 var ErrSessionAlreadyInitialized = NewMcpError(ErrInvalidRequestCode, "session already initialized", nil)
-var ErrSessionNotFound = NewMcpError(ErrInvalidRequestCode, "session not found", nil)
+var ErrSessionNotFound = NewMcpError(ErrInvalidRequestCode, "session not found", nil).WithStatusCode(http.StatusNotFound)
+
+var ErrSessionNotInitialized = NewMcpError(ErrInvalidRequestCode, "session not initialized", nil).WithStatusCode(http.StatusBadRequest)
 
-var ErrSessionNotInitialized = NewMcpError(ErrInvalidRequestCode, "session not initialized", nil)
+var ErrSessionDataNotFound = NewMcpError(ErrInvalidParametersCode, "session data not found", nil)
+var ErrSessionDataVersionConflict = NewMcpError(ErrInvalidRequestCode, "session data version conflict", nil)
+var ErrSessionTerminationNotSupported = NewMcpError(ErrInvalidRequestCode, "session termination not supported", nil).WithStatusCode(http.StatusMethodNotAllowed)
+var ErrSessionDataNotSupported = NewMcpError(ErrInternalErrorCode, "session manager does not support session data", nil)
+
+var ErrTransportNotBidirectional = NewMcpError(ErrInternalErrorCode, "transport does not support server-to-client requests", nil)
+var ErrSamplingNotSupported = NewMcpError(ErrInvalidRequestCode, "client does not support sampling", nil)
+var ErrRootsNotSupported = NewMcpError(ErrInvalidRequestCode, "client does not support roots", nil)
+var ErrElicitationNotSupported = NewMcpError(ErrInvalidRequestCode, "client does not support elicitation", nil)
 
 var ErrInvalidMcpRequestParameters = NewMcpError(ErrInvalidParametersCode, "invalid params", nil)
+var ErrInvalidCursor = NewMcpError(ErrInvalidParametersCode, "invalid cursor", nil)
 var ErrInvalidToolArguments = NewMcpError(ErrInvalidParametersCode, "invalid tool arguments", nil)
 
+// libraryErrors are the errors of the library which tools may get back, e.g. from CreateMessage or SetSessionValue.
+// A tool returning one of them is sent to the client as a tool error rather than a JSON-RPC error,
+// so the model can see the tool could not complete.
+var libraryErrors = []*McpError{
+	ErrInvalidRequest, ErrNoSessionHeader, ErrSessionAlreadyInitialized, ErrSessionNotFound, ErrSessionNotInitialized,
+	ErrSessionDataNotFound, ErrSessionDataVersionConflict, ErrSessionTerminationNotSupported, ErrSessionDataNotSupported,
+	ErrTransportNotBidirectional, ErrSamplingNotSupported, ErrRootsNotSupported, ErrElicitationNotSupported,
+	ErrInvalidMcpRequestParameters, ErrInvalidCursor, ErrInvalidToolArguments,
+}
+
+// McpClientError is a JSON-RPC error returned by the client in response to a request sent by the server,
+// e.g. when the user rejects a sampling request. It is not an McpError, so a tool returning it produces a tool error.
+type McpClientError struct {
+	Method  string // Method of the request sent to the client
+	Code    int    // Error code
+	Message string // Error message
+	Data    any    // Error data
+}
+
+func (e *McpClientError) Error() string {
+	return fmt.Sprintf("client returned error for %s: %s (code %d)", e.Method, e.Message, e.Code)
+}
+
 func NewErrUnknownMethod(method string) *McpError {
 	return &McpError{
 		Code:    ErrMethodNotFoundCode,
@@ -55,6 +114,21 @@ func NewErrInvalidArgumentType(name string, expected McpToolDataType) *McpError
 	}
 }
 
+// NewErrLimitExceeded creates an error for an exceeded concurrency or rate limit.
+// The data contains retryAfter, the number of seconds the client should wait before retrying.
+// HTTP transports answer with status 429 and a Retry-After header, since HTTP clients may not read the body of a 429 response.
+func NewErrLimitExceeded(message string, retryAfter time.Duration) *McpError {
+	return &McpError{
+		Code:    ErrLimitExceededCode,
+		Message: message,
+		Data: map[string]any{
+			"retryAfter": math.Ceil(retryAfter.Seconds()*1000) / 1000,
+		},
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: retryAfter,
+	}
+}
+
 func NewErrInternalError(message string, data any) *McpError {
 	return &McpError{
 		Code:    ErrInternalErrorCode,
@@ -62,3 +136,27 @@ func NewErrInternalError(message string, data any) *McpError {
 		Data:    data,
 	}
 }
+
+// asMcpError returns the first McpError in the chain of err, whether it is an *McpError or an McpError value.
+func asMcpError(err error) (*McpError, bool) {
+	var ptr *McpError
+	if errors.As(err, &ptr) && ptr != nil {
+		return ptr, true
+	}
+	var val McpError
+	if errors.As(err, &val) {
+		return &val, true
+	}
+	return nil, false
+}
+
+// asToolMcpError returns the McpError built by a tool function to be sent to the client as a JSON-RPC error.
+// Errors of the library in libraryErrors are not returned, so they are sent as tool errors.
+func asToolMcpError(err error) (*McpError, bool) {
+	for _, libErr := range libraryErrors {
+		if errors.Is(err, libErr) {
+			return nil, false
+		}
+	}
+	return asMcpError(err)
+}
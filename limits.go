@@ -0,0 +1,227 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// McpRateLimit is a token bucket rate limit. The bucket holds up to Burst calls and refills at Rate calls per second.
+// A zero Rate means no limit.
+type McpRateLimit struct {
+	Rate  float64 // Calls per second
+	Burst int     // Maximum number of calls in a burst. Values below 1 are treated as 1
+}
+
+// tokenBucket is the state of a rate limit.
+type tokenBucket struct {
+	tokens float64   // Available tokens
+	last   time.Time // Time of the last refill
+}
+
+// take takes a token from the bucket. If the bucket is empty, it returns false and the time until the next token.
+func (b *tokenBucket) take(limit McpRateLimit, now time.Time) (bool, time.Duration) {
+	burst := float64(max(limit.Burst, 1))
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// full reports whether the bucket has refilled completely, so it can be discarded.
+func (b *tokenBucket) full(limit McpRateLimit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(max(limit.Burst, 1))
+}
+
+// bucketSweepInterval is the minimum interval between discarding refilled token buckets.
+const bucketSweepInterval = time.Minute
+
+// toolLimiter tracks running tool calls and rate limit buckets of the server.
+type toolLimiter struct {
+	mu        sync.Mutex
+	running   int                     // Running tool calls
+	perTool   map[string]int          // Running calls per tool
+	buckets   map[string]*tokenBucket // Buckets keyed by session, or session and tool
+	global    map[string]*tokenBucket // Buckets of tools across all sessions keyed by tool
+	lastSweep time.Time               // Time buckets were last discarded
+}
+
+// SetToolMaxConcurrency sets the maximum number of concurrent tools/call executions of a registered tool.
+// Zero means no limit.
+func (s *McpServer) SetToolMaxConcurrency(name string, n int) error {
+	return s.updateTool(name, func(t *McpTool) {
+		t.SetMaxConcurrency(n)
+	})
+}
+
+// SetToolRateLimit sets the rate limit of tools/call of a registered tool for each session.
+// Every session has its own bucket, so the tool is called at most limit.Rate times per second by each session.
+// Use SetToolGlobalRateLimit to limit the calls of the tool across all sessions.
+func (s *McpServer) SetToolRateLimit(name string, limit McpRateLimit) error {
+	return s.updateTool(name, func(t *McpTool) {
+		t.SetRateLimit(limit)
+	})
+}
+
+// SetToolGlobalRateLimit sets the rate limit of tools/call of a registered tool across all sessions.
+func (s *McpServer) SetToolGlobalRateLimit(name string, limit McpRateLimit) error {
+	return s.updateTool(name, func(t *McpTool) {
+		t.SetGlobalRateLimit(limit)
+	})
+}
+
+// acquireTool checks the concurrency and rate limits of a tools/call in the session.
+// On success, it returns a function releasing the concurrency slot, which must be called when the tool returns.
+// Otherwise, it returns ErrLimitExceeded with the time the client should wait before retrying.
+func (s *McpServer) acquireTool(sessionID string, tool McpTool) (func(), *McpError) {
+	l := &s.limiter
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if s.MaxConcurrentToolCalls > 0 && l.running >= s.MaxConcurrentToolCalls {
+		return nil, NewErrLimitExceeded("too many concurrent tool calls", DefaultLimitRetryAfter)
+	}
+	if tool.MaxConcurrency > 0 && l.perTool[tool.Name] >= tool.MaxConcurrency {
+		return nil, NewErrLimitExceeded(fmt.Sprintf("too many concurrent calls of tool %s", tool.Name), DefaultLimitRetryAfter)
+	}
+
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+		l.global = make(map[string]*tokenBucket)
+		l.lastSweep = now
+	}
+	if now.Sub(l.lastSweep) >= bucketSweepInterval {
+		s.sweepBuckets(now)
+	}
+
+	// Check all buckets before taking tokens, so a rejected call does not consume tokens
+	type limitedBucket struct {
+		bucket *tokenBucket
+		limit  McpRateLimit
+	}
+	var limited []limitedBucket
+	if s.SessionRateLimit.Rate > 0 {
+		limited = append(limited, limitedBucket{l.bucket(sessionID, now, s.SessionRateLimit), s.SessionRateLimit})
+	}
+	if tool.RateLimit.Rate > 0 {
+		limited = append(limited, limitedBucket{l.bucket(sessionID+"\x00"+tool.Name, now, tool.RateLimit), tool.RateLimit})
+	}
+	if tool.GlobalRateLimit.Rate > 0 {
+		limited = append(limited, limitedBucket{bucketOf(l.global, tool.Name, now, tool.GlobalRateLimit), tool.GlobalRateLimit})
+	}
+	for _, b := range limited {
+		probe := *b.bucket
+		if ok, retryAfter := probe.take(b.limit, now); !ok {
+			return nil, NewErrLimitExceeded("rate limit exceeded", retryAfter)
+		}
+	}
+	for _, b := range limited {
+		b.bucket.take(b.limit, now)
+	}
+
+	l.running++
+	if l.perTool == nil {
+		l.perTool = make(map[string]int)
+	}
+	l.perTool[tool.Name]++
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.running--
+		if l.perTool[tool.Name]--; l.perTool[tool.Name] <= 0 {
+			delete(l.perTool, tool.Name)
+		}
+	}, nil
+}
+
+// toolSlot is a concurrency slot of a tools/call shared with the tool function goroutines started for the call.
+// The slot is released when the call and all goroutines have finished, so a tool abandoned after its timeout
+// keeps counting against the concurrency limits until it actually returns.
+type toolSlot struct {
+	refs    atomic.Int32
+	release func()
+}
+
+type toolSlotContextKey struct{}
+
+// newToolSlot returns a slot held by the caller and a context carrying it to the tool function goroutines.
+func newToolSlot(ctx context.Context, release func()) (context.Context, *toolSlot) {
+	slot := &toolSlot{release: release}
+	slot.hold()
+	return context.WithValue(ctx, toolSlotContextKey{}, slot), slot
+}
+
+// toolSlotFromContext returns the slot of the tools/call of ctx, or nil if ctx is not a tools/call, e.g. CallTool.
+func toolSlotFromContext(ctx context.Context) *toolSlot {
+	slot, _ := ctx.Value(toolSlotContextKey{}).(*toolSlot)
+	return slot
+}
+
+// hold adds a holder of the slot. A nil slot is ignored.
+func (t *toolSlot) hold() {
+	if t != nil {
+		t.refs.Add(1)
+	}
+}
+
+// done removes a holder of the slot and releases the slot when the last holder is done. A nil slot is ignored.
+func (t *toolSlot) done() {
+	if t != nil && t.refs.Add(-1) == 0 {
+		t.release()
+	}
+}
+
+// bucket returns the bucket of key, creating a full bucket if it does not exist.
+func (l *toolLimiter) bucket(key string, now time.Time, limit McpRateLimit) *tokenBucket {
+	return bucketOf(l.buckets, key, now, limit)
+}
+
+// bucketOf returns the bucket of key in buckets, creating a full bucket if it does not exist.
+func bucketOf(buckets map[string]*tokenBucket, key string, now time.Time, limit McpRateLimit) *tokenBucket {
+	b, ok := buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(max(limit.Burst, 1)), last: now}
+		buckets[key] = b
+	}
+	return b
+}
+
+// sweepBuckets discards refilled buckets, which are equivalent to new buckets, so buckets of ended sessions do not accumulate.
+// The limiter must be locked.
+func (s *McpServer) sweepBuckets(now time.Time) {
+	l := &s.limiter
+	for key, b := range l.buckets {
+		limit := s.SessionRateLimit
+		if _, tool, ok := strings.Cut(key, "\x00"); ok {
+			t, err := s.GetTool(tool)
+			if err != nil {
+				delete(l.buckets, key)
+				continue
+			}
+			limit = t.RateLimit
+		}
+		if limit.Rate <= 0 || b.full(limit, now) {
+			delete(l.buckets, key)
+		}
+	}
+	for name, b := range l.global {
+		t, err := s.GetTool(name)
+		if err != nil || t.GlobalRateLimit.Rate <= 0 || b.full(t.GlobalRateLimit, now) {
+			delete(l.global, name)
+		}
+	}
+	l.lastSweep = now
+}
@@ -1,12 +1,26 @@
 package mcp
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 type McpRequest struct {
 	JsonRPC string      `json:"jsonrpc"` // JSON-RPC version
 	ID      json.Number `json:"id"`      // Request ID
 	Method  string      `json:"method"`  // Method name
 	Params  any         `json:"params"`  // Parameters
+
+	// Response to a server-to-client request. Set only when the client responds to a request sent by the server.
+	Result json.RawMessage `json:"result,omitempty"` // Result
+	Error  *McpError       `json:"error,omitempty"`  // Error
+}
+
+// McpNotification is a JSON-RPC notification sent by the server to the client. Notifications have no ID and no response.
+type McpNotification struct {
+	JsonRPC string `json:"jsonrpc"`          // JSON-RPC version
+	Method  string `json:"method"`           // Method name
+	Params  any    `json:"params,omitempty"` // Parameters
 }
 
 type McpResponse struct {
@@ -14,6 +28,44 @@ type McpResponse struct {
 	ID      json.Number    `json:"id"`               // Request ID
 	Results any            `json:"result,omitempty"` // Parameters
 	Error   *McpError      `json:"error,omitempty"`  // Error
+
+	StatusCode int  `json:"-"` // Status code hint for the transport layer. Overrides the status code of Error
+	NoBody     bool `json:"-"` // The transport layer must not write a response body, e.g. for notifications
+}
+
+// TransportStatusCode returns the status code hint for the transport layer, or zero to use the transport default.
+func (r *McpResponse) TransportStatusCode() int {
+	if r.StatusCode != 0 {
+		return r.StatusCode
+	}
+	if r.Error != nil {
+		return r.Error.StatusCode
+	}
+	return 0
+}
+
+// Initialize method request
+
+type McpInitializeRequest struct {
+	ProtocolVersion McpProtocolVersion    `json:"protocolVersion"` // Protocol version requested by the client
+	Capabilities    McpClientCapabilities `json:"capabilities"`    // Client capabilities
+	ClientInfo      McpClientInfo         `json:"clientInfo"`      // Client info
+}
+
+type McpClientInfo struct {
+	Name    string `json:"name"`    // Client name
+	Version string `json:"version"` // Client version
+}
+
+type McpClientCapabilities struct {
+	Roots        *McpCapabilityRoots `json:"roots,omitempty"`        // Roots capabilities
+	Sampling     any                 `json:"sampling,omitempty"`     // Sampling capabilities
+	Elicitation  any                 `json:"elicitation,omitempty"`  // Elicitation capabilities
+	Experimental any                 `json:"experimental,omitempty"` // Experimental capabilities
+}
+
+type McpCapabilityRoots struct {
+	ListChanged bool `json:"listChanged"` // List changed
 }
 
 // Initialize method response
@@ -50,11 +102,47 @@ type McpCapabilityTools struct {
 
 // Tool Response
 
+// McpMetaToolsHash is the _meta key of the tools/list result holding the hash of the tool list visible to the session.
+const McpMetaToolsHash = "toolsHash"
+
 type McpToolsListResponse struct {
-	Tools []McpToolDescriptor `json:"tools"` // List of tools
+	Tools      []McpToolDescriptor `json:"tools"`                // List of tools
+	NextCursor string              `json:"nextCursor,omitempty"` // Cursor of the next page. Empty on the last page
+	Meta       map[string]any      `json:"_meta,omitempty"`      // Metadata, e.g. the hash of the tool list
+}
+
+// Prompt Response
+
+type McpPromptsListResponse struct {
+	Prompts    []any  `json:"prompts"`              // List of prompts
+	NextCursor string `json:"nextCursor,omitempty"` // Cursor of the next page. Empty on the last page
+}
+
+// Resource Response
+
+type McpResourcesListResponse struct {
+	Resources  []any  `json:"resources"`            // List of resources
+	NextCursor string `json:"nextCursor,omitempty"` // Cursor of the next page. Empty on the last page
 }
 
 type McpToolCallResponse struct {
 	Content []McpToolOutput `json:"content"` // Contents of the tool call response
 	IsError bool            `json:"isError"` // Is error
 }
+
+// DecodeParams decodes request parameters into v.
+// Parameters are decoded by the transport layer into generic values, so they are re-encoded as JSON first.
+func DecodeParams(params any, v any) error {
+	if params == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("cannot encode params: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("cannot decode params: %w", err)
+	}
+	return nil
+}
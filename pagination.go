@@ -0,0 +1,74 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// McpPaginatedRequest is the parameters of list methods supporting pagination,
+// e.g. tools/list, prompts/list and resources/list.
+// See. https://modelcontextprotocol.io/specification/2025-03-26/server/utilities/pagination
+type McpPaginatedRequest struct {
+	Cursor string `json:"cursor,omitempty"` // Opaque cursor returned as nextCursor of the previous page
+}
+
+// encodeCursor encodes the position of the last item of a page as an opaque cursor.
+func encodeCursor(position string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("pos:" + position))
+}
+
+// decodeCursor decodes the position of the last item of the previous page from a cursor.
+func decodeCursor(cursor string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("malformed cursor: %w", err)
+	}
+	position, ok := strings.CutPrefix(string(data), "pos:")
+	if !ok {
+		return "", fmt.Errorf("malformed cursor: %s", cursor)
+	}
+	return position, nil
+}
+
+// resumeIndex resumes a list whose position is the index of the item.
+func resumeIndex(position string) (int, error) {
+	i, err := strconv.Atoi(position)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("malformed index: %s", position)
+	}
+	return i + 1, nil
+}
+
+// paginate returns the page of items following the position encoded in cursor and the cursor of the next page,
+// which is empty on the last page. position returns the position of the item at index i, and resume returns the index
+// of the first item after a position, which need not be the position of an item in the list. Resuming by position
+// rather than offset keeps pages stable when items are added or removed, including the last item of the page.
+// An empty cursor is the first page. If pageSize is zero or negative, all remaining items are returned.
+func paginate[T any](items []T, position func(i int) string, resume func(position string) (int, error), cursor string, pageSize int) ([]T, string, error) {
+	start := 0
+	if cursor != "" {
+		last, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if start, err = resume(last); err != nil {
+			return nil, "", err
+		}
+		if start > len(items) {
+			return nil, "", fmt.Errorf("cursor out of range: %s", last)
+		}
+	}
+
+	end := len(items)
+	if pageSize > 0 && start+pageSize < end {
+		end = start + pageSize
+	}
+
+	next := ""
+	if end < len(items) {
+		next = encodeCursor(position(end - 1))
+	}
+	return items[start:end], next, nil
+}
@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+)
+
+// McpPanic describes a panic recovered by the server.
+type McpPanic struct {
+	Method string // JSON-RPC method being processed. Empty for panics in tool functions
+	Tool   string // Name of the tool. Empty for panics outside tool functions
+	Value  any    // Value passed to panic
+	Stack  []byte // Stack trace of the panicking goroutine
+}
+
+// recoverPanic reports a recovered panic to the log and PanicHandler.
+func (s *McpServer) recoverPanic(ctx context.Context, p McpPanic) {
+	if p.Tool != "" {
+		s.Logf("Tool %s panicked: %v", p.Tool, p.Value)
+	} else {
+		s.Logf("Method %s panicked: %v", p.Method, p.Value)
+	}
+	s.Debugf("%s", p.Stack)
+
+	if s.PanicHandler != nil {
+		s.PanicHandler(ctx, p)
+	}
+}
+
+// callMethod calls the method and converts a panic into an internal error response.
+// If ExposePanics is set, the panic value and stack trace are included in the error data.
+func (s *McpServer) callMethod(ctx context.Context, method McpMethodFunc, req *McpRequest) (resp *McpResponse, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		p := McpPanic{Method: req.Method, Value: r, Stack: debug.Stack()}
+		s.recoverPanic(ctx, p)
+
+		var data any
+		if s.ExposePanics {
+			data = map[string]any{
+				"panic": fmt.Sprint(p.Value),
+				"stack": string(p.Stack),
+			}
+		}
+		resp, err = s.CreateMcpErrorResponse(ctx, NewErrInternalError("internal error", data))
+	}()
+
+	return method(ctx, req)
+}
+
+// callToolFunction calls the tool function. If the function panics, it returns a tool error output instead.
+// If ExposePanics is set, the panic value and stack trace are included in the error text.
+func (s *McpServer) callToolFunction(ctx context.Context, name string, f reflect.Value, args []reflect.Value) (out []reflect.Value, panicOutput []McpToolOutput) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		p := McpPanic{Tool: name, Value: r, Stack: debug.Stack()}
+		s.recoverPanic(ctx, p)
+
+		text := fmt.Sprintf("tool %s panicked", name)
+		if s.ExposePanics {
+			text = fmt.Sprintf("%s: %v\n%s", text, p.Value, p.Stack)
+		}
+		panicOutput = []McpToolOutput{
+			{
+				Type: McpToolOutputTypeError,
+				Text: text,
+			},
+		}
+	}()
+
+	return f.Call(args), nil
+}
@@ -0,0 +1,232 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// McpRoot is a root directory or file the client allows the server to operate on.
+// See. https://modelcontextprotocol.io/specification/2025-03-26/client/roots
+type McpRoot struct {
+	URI  string `json:"uri"`            // URI of the root. Currently only file:// URIs are allowed
+	Name string `json:"name,omitempty"` // Human-readable name of the root
+}
+
+// McpListRootsResult is the result of roots/list request.
+type McpListRootsResult struct {
+	Roots []McpRoot `json:"roots"` // Roots exposed by the client
+}
+
+// McpSessionDataKeyRoots is the session data key caching the roots of the client.
+const McpSessionDataKeyRoots = "mcp/roots"
+
+// rootsCache is the value stored under McpSessionDataKeyRoots.
+// notifications/roots/list_changed replaces it with an invalid entry instead of deleting it, so a roots/list request
+// started before the notification fails to cache its result on the version check.
+type rootsCache struct {
+	Roots []McpRoot `json:"roots,omitempty"` // Roots exposed by the client
+	Valid bool      `json:"valid"`           // Roots are up to date
+}
+
+// rootsInvalidateAttempts is the number of attempts to invalidate the cached roots when other requests update them concurrently.
+const rootsInvalidateAttempts = 5
+
+// ListRoots returns the roots exposed by the client of the current session.
+// The roots are requested from the client with roots/list request on first use and cached in the session
+// until the client sends notifications/roots/list_changed.
+//
+// It returns ErrRootsNotSupported if the client did not advertise the roots capability.
+func ListRoots(ctx context.Context) ([]McpRoot, error) {
+	s, err := GetServerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := GetSessionClientFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get session client: %w", err)
+	}
+	if client.Capabilities.Roots == nil {
+		return nil, ErrRootsNotSupported
+	}
+
+	var cached rootsCache
+	version, err := GetSessionValue(ctx, McpSessionDataKeyRoots, &cached)
+	if err != nil && !errors.Is(err, ErrSessionDataNotFound) {
+		return nil, err
+	}
+	if cached.Valid {
+		return cached.Roots, nil
+	}
+
+	result := new(McpListRootsResult)
+	if err := s.SendRequest(ctx, "roots/list", nil, result); err != nil {
+		return nil, err
+	}
+
+	// The roots are not cached if they changed or another request cached them in the meantime.
+	// Caching is best effort, e.g. session managers may limit the size of the session data
+	cached = rootsCache{Roots: result.Roots, Valid: true}
+	if _, err := SetSessionValue(ctx, McpSessionDataKeyRoots, cached, version); err != nil && !errors.Is(err, ErrSessionDataVersionConflict) {
+		s.Logf("Cannot cache roots: %v", err)
+	}
+	return result.Roots, nil
+}
+
+// MethodNotificationRootsListChanged invalidates the cached roots of the session.
+// Notifications without a session are ignored, since there are no cached roots.
+func (s *McpServer) MethodNotificationRootsListChanged(ctx context.Context, req *McpRequest) (*McpResponse, error) {
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		// Something went wrong with the session
+		return nil, err
+	}
+	if sess.SessionID == "" {
+		return s.CreateMcpResponse(ctx, nil)
+	}
+
+	// Session is not initialized
+	if !sess.Initialized {
+		return s.CreateMcpErrorResponse(ctx, ErrSessionNotInitialized)
+	}
+
+	for range rootsInvalidateAttempts {
+		var cached rootsCache
+		version, err := GetSessionValue(ctx, McpSessionDataKeyRoots, &cached)
+		if errors.Is(err, ErrSessionDataNotSupported) {
+			// Roots are never cached without a session data store
+			return s.CreateMcpResponse(ctx, nil)
+		}
+		if err != nil && !errors.Is(err, ErrSessionDataNotFound) {
+			return nil, err
+		}
+
+		// Retry if the roots were cached concurrently
+		_, err = SetSessionValue(ctx, McpSessionDataKeyRoots, rootsCache{}, version)
+		if err == nil {
+			return s.CreateMcpResponse(ctx, nil)
+		}
+		if !errors.Is(err, ErrSessionDataVersionConflict) {
+			return nil, err
+		}
+	}
+	return nil, ErrSessionDataVersionConflict
+}
+
+// PathInRoots reports whether path lies inside one of the roots. path can be a file path or a file:// URI.
+// Relative paths and roots with other schemes never match.
+//
+// The check is lexical: symbolic links are not resolved, so a path inside a root may point outside of it.
+// Use PathInRootsResolved before accessing the file system.
+func PathInRoots(roots []McpRoot, path string) bool {
+	path, ok := rootsPath(path)
+	if !ok {
+		return false
+	}
+
+	for _, root := range roots {
+		rootPath, ok := fileURIPath(root.URI)
+		if !ok {
+			continue
+		}
+		if pathInRoot(rootPath, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// PathInRootsResolved is like PathInRoots but resolves symbolic links in path and the roots with filepath.EvalSymlinks first.
+// Path elements which do not exist yet, e.g. a file to be created, are kept as they are.
+// It returns an error if a link cannot be resolved.
+func PathInRootsResolved(roots []McpRoot, path string) (bool, error) {
+	path, ok := rootsPath(path)
+	if !ok {
+		return false, nil
+	}
+	path, err := resolvePath(path)
+	if err != nil {
+		return false, err
+	}
+
+	for _, root := range roots {
+		rootPath, ok := fileURIPath(root.URI)
+		if !ok {
+			continue
+		}
+		rootPath, err := resolvePath(rootPath)
+		if err != nil {
+			return false, err
+		}
+		if pathInRoot(rootPath, path) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rootsPath converts a file path or file:// URI to a cleaned absolute file path.
+func rootsPath(path string) (string, bool) {
+	if strings.HasPrefix(path, "file://") {
+		p, ok := fileURIPath(path)
+		if !ok {
+			return "", false
+		}
+		path = p
+	}
+	if !filepath.IsAbs(path) {
+		return "", false
+	}
+	return filepath.Clean(path), true
+}
+
+// pathInRoot reports whether the cleaned path lies inside the cleaned root path.
+func pathInRoot(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// resolvePath resolves symbolic links in the longest existing prefix of the absolute path.
+func resolvePath(path string) (string, error) {
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, rest...)...), nil
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// fileURIPath returns the cleaned file path of a file:// URI.
+// On Windows, the slash before the drive letter is removed, e.g. file:///C:/work is C:\work.
+// URIs with a host other than localhost, such as UNC paths, are not supported.
+func fileURIPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Path == "" || (u.Host != "" && u.Host != "localhost") {
+		return "", false
+	}
+	path := u.Path
+	if len(path) > 1 && path[0] == '/' && filepath.VolumeName(path[1:]) != "" {
+		// VolumeName is always empty on other systems
+		path = path[1:]
+	}
+	return filepath.Clean(filepath.FromSlash(path)), true
+}
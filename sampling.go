@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+)
+
+// McpSamplingMessage is a message in a sampling request or result.
+type McpSamplingMessage struct {
+	Role    string        `json:"role"`    // Role of the message sender: user or assistant
+	Content McpToolOutput `json:"content"` // Text, image or audio content
+}
+
+type McpModelHint struct {
+	Name string `json:"name,omitempty"` // Model name or substring of model name
+}
+
+type McpModelPreferences struct {
+	Hints                []McpModelHint `json:"hints,omitempty"`                // Model hints in order of preference
+	CostPriority         *float64       `json:"costPriority,omitempty"`         // Cost priority (0-1)
+	SpeedPriority        *float64       `json:"speedPriority,omitempty"`        // Speed priority (0-1)
+	IntelligencePriority *float64       `json:"intelligencePriority,omitempty"` // Intelligence priority (0-1)
+}
+
+// McpCreateMessageRequest is the parameters of sampling/createMessage request.
+// See. https://modelcontextprotocol.io/specification/2025-03-26/client/sampling
+type McpCreateMessageRequest struct {
+	Messages         []McpSamplingMessage `json:"messages"`                   // Conversation messages
+	ModelPreferences *McpModelPreferences `json:"modelPreferences,omitempty"` // Model preferences
+	SystemPrompt     string               `json:"systemPrompt,omitempty"`     // System prompt
+	IncludeContext   string               `json:"includeContext,omitempty"`   // Context to include: none, thisServer or allServers
+	Temperature      *float64             `json:"temperature,omitempty"`      // Sampling temperature
+	MaxTokens        int                  `json:"maxTokens"`                  // Maximum number of tokens to sample
+	StopSequences    []string             `json:"stopSequences,omitempty"`    // Stop sequences
+	Metadata         any                  `json:"metadata,omitempty"`         // Provider-specific metadata
+}
+
+// McpCreateMessageResult is the result of sampling/createMessage request.
+type McpCreateMessageResult struct {
+	Role       string        `json:"role"`                 // Role of the message sender
+	Content    McpToolOutput `json:"content"`              // Generated content
+	Model      string        `json:"model"`                // Name of the model used
+	StopReason string        `json:"stopReason,omitempty"` // Reason sampling stopped
+}
+
+// CreateMessage asks the client of the current session to sample a message from its LLM
+// by sending sampling/createMessage request, and waits for the result.
+// It can be called from tool functions with the context passed to the tool.
+//
+// It returns ErrSamplingNotSupported if the client did not advertise the sampling capability.
+func CreateMessage(ctx context.Context, req McpCreateMessageRequest) (*McpCreateMessageResult, error) {
+	s, err := GetServerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := GetSessionClientFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get session client: %w", err)
+	}
+	if client.Capabilities.Sampling == nil {
+		return nil, ErrSamplingNotSupported
+	}
+
+	result := new(McpCreateMessageResult)
+	if err := s.SendRequest(ctx, "sampling/createMessage", req, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -2,19 +2,33 @@ package mcp
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type McpProtocolVersion string
 
 const McpProtocol2025_30_26 McpProtocolVersion = "2025-03-26" // MCP protocol version 2025-03-26
+const McpProtocol2025_06_18 McpProtocolVersion = "2025-06-18" // MCP protocol version 2025-06-18
 
 type McpMethodFunc func(ctx context.Context, req *McpRequest) (*McpResponse, error)
 
+// McpMiddleware wraps a method, e.g. for authentication, logging or metrics.
+// A middleware can inspect or change the request before calling next, inspect or replace the response after,
+// or return a response without calling next. The session is available with GetSessionFromContext.
+type McpMiddleware func(next McpMethodFunc) McpMethodFunc
+
 type JsonRPCVersion string
 
 const JsonRPCVersion2_0 JsonRPCVersion = "2.0" // JSON-RPC version 2.0
@@ -32,6 +46,10 @@ type McpServer struct {
 	TransportHandler McpTransportHandler // Transport handler
 	SessionManager   McpSessionManager   // Session manager
 
+	// Reject requests without a session, other than initialize, with ErrNoSessionHeader.
+	// If false, such requests are processed with an empty session which is not stored.
+	StrictSession bool
+
 	JsonRPC         JsonRPCVersion     // JSON-RPC version
 	ProtocolVersion McpProtocolVersion // Protocol version
 
@@ -39,17 +57,56 @@ type McpServer struct {
 	Version      string // Server version
 	Instructions string // Instructions describing how to use the server and its features.
 
-	Methods map[string]McpMethodFunc // List of methods
+	Methods     map[string]McpMethodFunc // List of methods
+	middlewares []McpMiddleware          // Middlewares wrapping methods. The first middleware is the outermost
 
 	Logging   bool               // Enable logging
 	Prompts   []any              // List of prompts
 	Resources []any              // List of resources
-	Tools     map[string]McpTool // List of tools
+	Tools     map[string]McpTool // List of tools. Use RegisterTool, ReplaceTool and UnregisterTool to change tools while serving
+
+	toolsMu          sync.RWMutex         // Guards Tools
+	toolSeq          uint64               // Registration sequence of the last registered tool
+	toolInterceptors []McpToolInterceptor // Interceptors wrapping tool calls. The first interceptor is the outermost
+
+	// ToolSorter reports whether tool a is listed before tool b in tools/list.
+	// If nil, tools are listed in registration order. Pagination cursors resume after the name and registration order
+	// of the last tool of a page, so the sorter should only depend on them to keep pages stable when tools are removed.
+	ToolSorter func(a, b McpTool) bool
+
+	// ToolFilter decides whether a tool is visible to a session. Hidden tools are omitted from tools/list
+	// and cannot be called with tools/call. If nil, all tools are visible.
+	// The filter is called on a snapshot of the tools without holding the registry lock.
+	// It is not applied to notifications/tools/list_changed, which are sent to all connected sessions.
+	ToolFilter func(ctx context.Context, session McpSession, tool McpTool) bool
+
+	// PanicHandler is called when a panic in a method or tool function is recovered, e.g. to report it to an error tracker.
+	// Panics in methods are returned to the client as internal errors and panics in tools as tool errors.
+	PanicHandler func(ctx context.Context, p McpPanic)
+	// ExposePanics includes the panic value and stack trace in responses to the client, e.g. for local development.
+	// It is independent of LogLevel so that debug logging never leaks internals to clients.
+	ExposePanics bool
+
+	ToolTimeout    time.Duration // Default execution time limit of tools without their own timeout. Zero means no limit
+	DeadlineMargin time.Duration // Time reserved before the deadline of the incoming context to send the response. Zero uses DefaultDeadlineMargin
+
+	MaxConcurrentToolCalls int          // Maximum number of concurrent tools/call executions of the server. Zero means no limit
+	SessionRateLimit       McpRateLimit // Rate limit of tools/call of each session across all tools
+	limiter                toolLimiter  // State of concurrency and rate limits
+
+	PageSize int // Maximum number of items in a page of tools/list, prompts/list and resources/list. Zero returns all items
+
+	ClientRequestTimeout time.Duration // Timeout of requests sent to the client, e.g. sampling. Zero uses DefaultClientRequestTimeout
+
+	pendingMu sync.Mutex                      // Guards pending
+	pending   map[pendingKey]chan *McpRequest // Requests sent to the client waiting for response
 }
 
+const DefaultDeadlineMargin = 500 * time.Millisecond // Default time reserved before the deadline of the incoming context
+
 func NewMcpServer(name string, version string, protocolVersion McpProtocolVersion) (*McpServer, error) {
 	switch protocolVersion {
-	case McpProtocol2025_30_26:
+	case McpProtocol2025_30_26, McpProtocol2025_06_18:
 		// Valid protocol version
 	default:
 		return nil, fmt.Errorf("unsupported protocol version: %s", protocolVersion)
@@ -71,6 +128,7 @@ func NewMcpServer(name string, version string, protocolVersion McpProtocolVersio
 	// Register default methods
 	s.RegisterMethod("initialize", s.MethodInitialize)
 	s.RegisterMethod("notifications/initialized", s.MethodNotificationInitialized)
+	s.RegisterMethod("notifications/roots/list_changed", s.MethodNotificationRootsListChanged)
 	s.RegisterMethod("tools/list", s.MethodToolsList)
 	s.RegisterMethod("tools/call", s.MethodToolsCall)
 
@@ -93,6 +151,18 @@ func (s *McpServer) Logf(format string, a ...interface{}) {
 	}
 }
 
+// builtinMethod returns the method serving a request not handled by a registered method.
+// prompts/list and resources/list list Prompts and Resources unless a method with the same name is registered.
+func (s *McpServer) builtinMethod(name string) (McpMethodFunc, bool) {
+	switch name {
+	case "prompts/list":
+		return s.MethodPromptsList, true
+	case "resources/list":
+		return s.MethodResourcesList, true
+	}
+	return nil, false
+}
+
 // RegisterMethod registers a method with the server.
 // If the method is already registered, it returns an error.
 // The method name must be unique.
@@ -111,6 +181,28 @@ func (s *McpServer) RegisterMethod(name string, method McpMethodFunc) error {
 	return nil
 }
 
+// Use adds middlewares wrapping every registered method. Middlewares run in the order they are added,
+// so the first middleware sees the request first and the response last.
+// Responses of the client to server-to-client requests (e.g. sampling/createMessage) pass through the middlewares too,
+// with an empty Method and Result or Error set. A middleware returning an error rejects the response,
+// and the pending request is not completed.
+// Middlewares must be added before the server starts processing requests.
+func (s *McpServer) Use(middlewares ...McpMiddleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+// MethodTerminateSession is the method of the request passed to middlewares when the client terminates its session,
+// e.g. with an HTTP DELETE. It is not a JSON-RPC method, so clients cannot call it.
+const MethodTerminateSession = "$/terminateSession"
+
+// wrapMethod wraps the method with the registered middlewares.
+func (s *McpServer) wrapMethod(method McpMethodFunc) McpMethodFunc {
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		method = s.middlewares[i](method)
+	}
+	return method
+}
+
 // CreateMcpResponse creates an MCP response with the given result
 func (s *McpServer) CreateMcpResponse(ctx context.Context, result any) (*McpResponse, error) {
 	reqID, err := GetRequestIDFromContext(ctx)
@@ -152,61 +244,268 @@ func (s *McpServer) ProcessRequest(ctx context.Context, req any) (any, error) {
 		return nil, fmt.Errorf("transport handler is not set")
 	}
 
+	// Terminate the session if requested by the client
+	if th, ok := s.TransportHandler.(McpTerminateTransportHandler); ok && th.IsTerminateSessionRequest(ctx, req) {
+		return s.terminateSession(ctx, req)
+	}
+
 	// Transfrom request from transport layer (e.g. AWS Lambda with steamable HTTP) to MCP request
 	mcpReq, err := s.TransportHandler.ProcessRequest(ctx, req)
 	if err != nil {
-		resp, _ := s.CreateMcpErrorResponse(ctx, NewMcpError(ErrInvalidRequestCode, "invalid request", nil))
+		resp, _ := s.CreateMcpErrorResponse(ctx, ErrInvalidRequest)
 		return s.TransportHandler.ProcessResponse(ctx, resp)
 	}
 	// Set request ID in context
 	ctx = SetRequestIDInContext(ctx, mcpReq.ID.String())
 
 	// Prepare session from the incoming request
-	// If session is not set, create a new session
+	// If session is not set, create a new session for initialize request only
 	sid, err := s.TransportHandler.GetSessionID(ctx, req)
 	if err != nil && !errors.Is(err, ErrNoSessionHeader) {
 		resp, _ := s.CreateMcpErrorResponse(ctx, NewErrInternalError("cannot get session", nil))
-		return s.TransportHandler.ProcessResponse(ctx, resp)
+		return s.respond(ctx, mcpReq, resp)
 	}
 
+	newSession := false
 	if sid == "" {
-		// Create a new session
-		mcpSession, err = s.SessionManager.CreateSession()
-		if err != nil {
-			resp, _ := s.CreateMcpErrorResponse(ctx, NewErrInternalError("cannot create session", nil))
-			return s.TransportHandler.ProcessResponse(ctx, resp)
+		switch {
+		case mcpReq.Method == "initialize":
+			// The session is created after the middlewares accept the request
+			newSession = true
+			mcpSession = McpSession{}
+		case s.StrictSession:
+			s.Logf("Method %s rejected: no session", mcpReq.Method)
+			resp, _ := s.CreateMcpErrorResponse(ctx, ErrNoSessionHeader)
+			return s.respond(ctx, mcpReq, resp)
+		default:
+			// Process the request without storing a session
+			mcpSession = McpSession{}
 		}
 	} else {
 		// Check if the session exists
-		sess, ok := s.SessionManager.GetSession(sid)
-		if !ok {
-			resp, _ := s.CreateMcpErrorResponse(ctx, NewErrInternalError("cannot get session", nil))
-			return s.TransportHandler.ProcessResponse(ctx, resp)
+		sess, err := s.lookupSession(sid)
+		if errors.Is(err, ErrSessionNotFound) {
+			// Session is unknown, expired or terminated
+			resp, _ := s.CreateMcpErrorResponse(ctx, ErrSessionNotFound)
+			return s.respond(ctx, mcpReq, resp)
+		}
+		if err != nil {
+			// The session store failed, so the client must keep the session and retry
+			s.Logf("Cannot get session %s: %v", sid, err)
+			resp, _ := s.CreateMcpErrorResponse(ctx, NewErrInternalError("cannot get session", nil).WithStatusCode(http.StatusInternalServerError))
+			return s.respond(ctx, mcpReq, resp)
 		}
 		mcpSession = sess
 	}
 
-	// Setup session in context
+	// Setup server, session and session manager in context
+	ctx = SetServerInContext(ctx, s)
 	ctx = SetSessionInContext(ctx, mcpSession)
+	ctx = SetSessionManagerInContext(ctx, s.SessionManager)
 
-	// Process the request
-	if _, ok := s.Methods[mcpReq.Method]; !ok {
+	// Process the request. Responses to requests sent by the server to the client pass through the middlewares too,
+	// so they can be authorized like requests
+	method, ok := s.Methods[mcpReq.Method]
+	if !ok {
+		method, ok = s.builtinMethod(mcpReq.Method)
+	}
+	if isClientResponse(mcpReq) {
+		method, ok = s.deliverClientResponse, true
+	}
+	if !ok {
 		s.Logf("Method %s not found", mcpReq.Method)
 		resp, _ := s.CreateMcpErrorResponse(ctx, NewErrUnknownMethod(mcpReq.Method))
-		return s.TransportHandler.ProcessResponse(ctx, resp)
+		return s.respond(ctx, mcpReq, resp)
+	}
+	if newSession {
+		method = s.withNewSession(method)
 	}
 
 	s.Logf("Processing method: %s", mcpReq.Method)
-	resp, err := s.Methods[mcpReq.Method](ctx, mcpReq)
+	resp, err := s.callMethod(ctx, s.wrapMethod(method), mcpReq)
 	if err != nil {
 		s.Logf("Error processing method %s: %v", mcpReq.Method, err)
-		resp, _ := s.CreateMcpErrorResponse(ctx, NewErrInternalError(err.Error(), nil))
-		return s.TransportHandler.ProcessResponse(ctx, resp)
+		mcpErr, ok := asMcpError(err)
+		if !ok {
+			mcpErr = NewErrInternalError(err.Error(), nil)
+		}
+		resp, _ := s.CreateMcpErrorResponse(ctx, mcpErr)
+		return s.respond(ctx, mcpReq, resp)
 	}
 
+	return s.respond(ctx, mcpReq, resp)
+}
+
+// respond transforms the response of the request to a transport-layer response.
+// Notifications and client responses never receive a response body: accepted messages are answered with HTTP 202
+// and rejected messages only with the error status code.
+func (s *McpServer) respond(ctx context.Context, req *McpRequest, resp *McpResponse) (any, error) {
+	if req.ID == "" || isClientResponse(req) {
+		status := http.StatusAccepted
+		if resp != nil && resp.Error != nil {
+			switch status = resp.TransportStatusCode(); {
+			case status != 0:
+			case resp.Error.Code == ErrInternalErrorCode:
+				status = http.StatusInternalServerError
+			default:
+				status = http.StatusBadRequest
+			}
+		}
+		resp = &McpResponse{StatusCode: status, NoBody: true}
+	}
 	return s.TransportHandler.ProcessResponse(ctx, resp)
 }
 
+// lookupSession retrieves the session with the given ID. It returns ErrSessionNotFound if the session does not exist.
+// Storage errors are only told apart from unknown sessions if the session manager implements McpSessionLookupManager.
+func (s *McpServer) lookupSession(sid string) (McpSession, error) {
+	if manager, ok := s.SessionManager.(McpSessionLookupManager); ok {
+		return manager.LookupSession(sid)
+	}
+	sess, ok := s.SessionManager.GetSession(sid)
+	if !ok {
+		return McpSession{}, ErrSessionNotFound
+	}
+	return sess, nil
+}
+
+// terminateSession deletes the session identified by the transport-layer request.
+// The termination passes through the middlewares as a MethodTerminateSession request, so it can be authorized like
+// other requests. Later requests using the same session ID are rejected with ErrSessionNotFound.
+func (s *McpServer) terminateSession(ctx context.Context, req any) (any, error) {
+	sid, err := s.TransportHandler.GetSessionID(ctx, req)
+	if err != nil {
+		resp, _ := s.CreateMcpErrorResponse(ctx, ErrNoSessionHeader)
+		return s.TransportHandler.ProcessResponse(ctx, resp)
+	}
+
+	sess, err := s.lookupSession(sid)
+	if errors.Is(err, ErrSessionNotFound) {
+		resp, _ := s.CreateMcpErrorResponse(ctx, ErrSessionNotFound)
+		return s.TransportHandler.ProcessResponse(ctx, resp)
+	}
+	if err != nil {
+		s.Logf("Cannot get session %s: %v", sid, err)
+		resp, _ := s.CreateMcpErrorResponse(ctx, NewErrInternalError("cannot get session", nil).WithStatusCode(http.StatusInternalServerError))
+		return s.TransportHandler.ProcessResponse(ctx, resp)
+	}
+
+	// The session is only set for the middlewares, the response does not carry the terminated session ID
+	methodCtx := SetServerInContext(ctx, s)
+	methodCtx = SetSessionInContext(methodCtx, sess)
+	methodCtx = SetSessionManagerInContext(methodCtx, s.SessionManager)
+
+	resp, err := s.callMethod(methodCtx, s.wrapMethod(s.deleteSession), &McpRequest{JsonRPC: string(s.JsonRPC), Method: MethodTerminateSession})
+	if err != nil {
+		mcpErr, ok := asMcpError(err)
+		switch {
+		case ok:
+		case errors.Is(err, ErrSessionNotFound):
+			mcpErr = ErrSessionNotFound
+		case errors.Is(err, ErrSessionTerminationNotSupported):
+			mcpErr = ErrSessionTerminationNotSupported
+		default:
+			s.Logf("Cannot delete session %s: %v", sid, err)
+			mcpErr = NewErrInternalError("cannot delete session", nil)
+		}
+		resp, _ = s.CreateMcpErrorResponse(ctx, mcpErr)
+		return s.TransportHandler.ProcessResponse(ctx, resp)
+	}
+	if resp != nil && resp.Error != nil {
+		// Rejected by a middleware
+		return s.TransportHandler.ProcessResponse(ctx, resp)
+	}
+
+	s.Logf("[%s] Session terminated", sid)
+
+	return s.TransportHandler.ProcessResponse(ctx, nil)
+}
+
+// deleteSession is the method of MethodTerminateSession requests. It deletes the session of the context.
+func (s *McpServer) deleteSession(ctx context.Context, req *McpRequest) (*McpResponse, error) {
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SessionManager.DeleteSession(sess.SessionID); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// withNewSession wraps the initialize method of a request without a session, so the session is only created
+// once the middlewares accept the request. The session is deleted again if the initialize method fails.
+func (s *McpServer) withNewSession(method McpMethodFunc) McpMethodFunc {
+	return func(ctx context.Context, req *McpRequest) (*McpResponse, error) {
+		sess, err := s.createSession(req)
+		if err != nil {
+			s.Logf("Cannot create session: %v", err)
+			return s.CreateMcpErrorResponse(ctx, NewErrInternalError("cannot create session", nil))
+		}
+		updateSessionInContext(ctx, sess)
+
+		resp, err := method(ctx, req)
+		if err != nil || (resp != nil && resp.Error != nil) {
+			if err := s.SessionManager.DeleteSession(sess.SessionID); err != nil && !errors.Is(err, ErrSessionTerminationNotSupported) {
+				s.Logf("Cannot delete session %s of failed initialize: %v", sess.SessionID, err)
+			}
+			updateSessionInContext(ctx, McpSession{})
+		}
+		return resp, err
+	}
+}
+
+// negotiateProtocolVersion returns the protocol version requested by the client if the server supports it,
+// otherwise the protocol version of the server.
+// See. https://modelcontextprotocol.io/specification/2025-06-18/basic/lifecycle#version-negotiation
+func (s *McpServer) negotiateProtocolVersion(requested McpProtocolVersion) McpProtocolVersion {
+	switch requested {
+	case McpProtocol2025_30_26, McpProtocol2025_06_18:
+		// Versions are dates, so later versions compare greater
+		if requested <= s.ProtocolVersion {
+			return requested
+		}
+	}
+	return s.ProtocolVersion
+}
+
+// createSession creates the session of an initialize request. The client and negotiated protocol version are recorded
+// in the session data store once, when the session is created, if the session manager provides a data store.
+// Recording is best effort: sessions whose client cannot be recorded use the protocol version of the server
+// and do not support server-to-client requests which depend on client capabilities.
+func (s *McpServer) createSession(req *McpRequest) (McpSession, error) {
+	sess, err := s.SessionManager.CreateSession()
+	if err != nil {
+		return McpSession{}, err
+	}
+
+	manager, ok := s.SessionManager.(McpSessionDataManager)
+	if !ok {
+		return sess, nil
+	}
+	var params McpInitializeRequest
+	if err := DecodeParams(req.Params, &params); err != nil {
+		// Invalid params are rejected by MethodInitialize
+		return sess, nil
+	}
+
+	value, err := json.Marshal(McpSessionClient{
+		ProtocolVersion: s.negotiateProtocolVersion(params.ProtocolVersion),
+		ClientInfo:      params.ClientInfo,
+		Capabilities:    params.Capabilities,
+	})
+	if err != nil {
+		return sess, fmt.Errorf("cannot encode session client: %w", err)
+	}
+	recorded, _, err := manager.SetSessionData(sess, McpSessionData{Key: McpSessionDataKeyClient, Value: value})
+	if err != nil {
+		// e.g. session managers may limit the size of the session data
+		s.Logf("Cannot record session client: %v", err)
+		return sess, nil
+	}
+	return recorded, nil
+}
+
 // MethodInitialize performs MCP initialize method and returns an MCP initialize response containing server information and capabilities.
 // See. https://modelcontextprotocol.io/specification/2025-03-26/basic/lifecycle#initialization
 func (s *McpServer) MethodInitialize(ctx context.Context, req *McpRequest) (*McpResponse, error) {
@@ -220,8 +519,14 @@ func (s *McpServer) MethodInitialize(ctx context.Context, req *McpRequest) (*Mcp
 		return s.CreateMcpErrorResponse(ctx, ErrSessionAlreadyInitialized)
 	}
 
+	var params McpInitializeRequest
+	if err := DecodeParams(req.Params, &params); err != nil {
+		s.Logf("Invalid initialize params: %v", err)
+		return s.CreateMcpErrorResponse(ctx, ErrInvalidMcpRequestParameters)
+	}
+
 	init := McpInitializeResponse{
-		ProtocolVersion: s.ProtocolVersion,
+		ProtocolVersion: s.negotiateProtocolVersion(params.ProtocolVersion),
 		Capabilities:    McpServerCapabilities{},
 		ServerInfo: McpServerInfo{
 			Name:    s.Name,
@@ -234,20 +539,27 @@ func (s *McpServer) MethodInitialize(ctx context.Context, req *McpRequest) (*Mcp
 		init.Capabilities.Logging = map[string]any{}
 	}
 
-	if len(s.Prompts) > 0 {
+	// Registered prompts/list and resources/list methods serve prompts and resources of their own
+	if _, ok := s.Methods["prompts/list"]; ok || len(s.Prompts) > 0 {
 		init.Capabilities.Prompts = &McpCapabilityPrompts{
 			ListChanged: false,
 		}
 	}
-	if len(s.Resources) > 0 {
+	if _, ok := s.Methods["resources/list"]; ok || len(s.Resources) > 0 {
 		init.Capabilities.Resources = &McpCapabilityResources{
 			ListChanged: false,
 			Subscribe:   false,
 		}
 	}
-	if len(s.Tools) > 0 {
+	// Changes of the tool list can only be notified through a bidirectional transport
+	_, listChanged := s.TransportHandler.(McpStreamTransportHandler)
+
+	s.toolsMu.RLock()
+	hasTools := len(s.Tools) > 0
+	s.toolsMu.RUnlock()
+	if hasTools || listChanged {
 		init.Capabilities.Tools = &McpCapabilityTools{
-			ListChanged: false,
+			ListChanged: listChanged,
 		}
 	}
 
@@ -270,11 +582,17 @@ func (s *McpServer) MethodNotificationInitialized(ctx context.Context, req *McpR
 		return s.CreateMcpErrorResponse(ctx, ErrSessionAlreadyInitialized)
 	}
 
+	// Nothing to record for requests without a session in non-strict mode
+	if sess.SessionID == "" {
+		return s.CreateMcpResponse(ctx, nil)
+	}
+
 	// Set session as initialized
-	_, err = s.SessionManager.SetSessionInitialized(sess, true)
+	sess, err = s.SessionManager.SetSessionInitialized(sess, true)
 	if err != nil {
 		return nil, err
 	}
+	updateSessionInContext(ctx, sess)
 
 	return s.CreateMcpResponse(ctx, nil)
 
@@ -293,12 +611,119 @@ func (s *McpServer) MethodToolsList(ctx context.Context, req *McpRequest) (*McpR
 		return s.CreateMcpErrorResponse(ctx, ErrSessionNotInitialized)
 	}
 
-	tools, err := s.ListTools()
+	var params McpPaginatedRequest
+	if err := DecodeParams(req.Params, &params); err != nil {
+		return s.CreateMcpErrorResponse(ctx, ErrInvalidMcpRequestParameters)
+	}
+
+	registered := s.sortedTools(func(tool McpTool) bool {
+		return s.toolVisible(ctx, sess, tool)
+	})
+	tools, err := describeTools(registered)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cursors hold the position of the last tool of the page, so pages do not shift when tools are registered or removed,
+	// including the last tool itself
+	position := func(i int) string {
+		return strconv.FormatUint(registered[i].seq, 10) + ":" + registered[i].Name
+	}
+	resume := func(key string) (int, error) {
+		seq, name, ok := strings.Cut(key, ":")
+		last, err := strconv.ParseUint(seq, 10, 64)
+		if !ok || err != nil {
+			return 0, fmt.Errorf("malformed tool position: %s", key)
+		}
+		probe := McpTool{Name: name, seq: last}
+		return sort.Search(len(registered), func(i int) bool { return s.toolLess(probe, registered[i]) }), nil
+	}
+	page, next, err := paginate(tools, position, resume, params.Cursor, s.PageSize)
+	if err != nil {
+		s.Logf("Invalid cursor: %v", err)
+		return s.CreateMcpErrorResponse(ctx, ErrInvalidCursor)
+	}
+	hash, err := hashTools(tools)
 	if err != nil {
 		return nil, err
 	}
+
+	// Tools have a title field since 2025-06-18. Older clients get the title in annotations
+	version := s.ProtocolVersion
+	if client, err := GetSessionClientFromContext(ctx); err == nil && client.ProtocolVersion != "" {
+		version = client.ProtocolVersion
+	}
+	if version < McpProtocol2025_06_18 {
+		page = annotateToolTitles(page)
+	}
 	resp := McpToolsListResponse{
-		Tools: tools,
+		Tools:      page,
+		NextCursor: next,
+		Meta:       map[string]any{McpMetaToolsHash: hash},
+	}
+
+	return s.CreateMcpResponse(ctx, resp)
+}
+
+// MethodPromptsList process MCP prompts/list method and returns a list of prompts.
+func (s *McpServer) MethodPromptsList(ctx context.Context, req *McpRequest) (*McpResponse, error) {
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		// Something went wrong with the session
+		return nil, err
+	}
+
+	// Session is not initialized
+	if !sess.Initialized {
+		return s.CreateMcpErrorResponse(ctx, ErrSessionNotInitialized)
+	}
+
+	var params McpPaginatedRequest
+	if err := DecodeParams(req.Params, &params); err != nil {
+		return s.CreateMcpErrorResponse(ctx, ErrInvalidMcpRequestParameters)
+	}
+
+	// Prompts are not changed while serving, so their position is the index
+	page, next, err := paginate(s.Prompts, strconv.Itoa, resumeIndex, params.Cursor, s.PageSize)
+	if err != nil {
+		s.Logf("Invalid cursor: %v", err)
+		return s.CreateMcpErrorResponse(ctx, ErrInvalidCursor)
+	}
+	resp := McpPromptsListResponse{
+		Prompts:    page,
+		NextCursor: next,
+	}
+
+	return s.CreateMcpResponse(ctx, resp)
+}
+
+// MethodResourcesList process MCP resources/list method and returns a list of resources.
+func (s *McpServer) MethodResourcesList(ctx context.Context, req *McpRequest) (*McpResponse, error) {
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		// Something went wrong with the session
+		return nil, err
+	}
+
+	// Session is not initialized
+	if !sess.Initialized {
+		return s.CreateMcpErrorResponse(ctx, ErrSessionNotInitialized)
+	}
+
+	var params McpPaginatedRequest
+	if err := DecodeParams(req.Params, &params); err != nil {
+		return s.CreateMcpErrorResponse(ctx, ErrInvalidMcpRequestParameters)
+	}
+
+	// Resources are not changed while serving, so their position is the index
+	page, next, err := paginate(s.Resources, strconv.Itoa, resumeIndex, params.Cursor, s.PageSize)
+	if err != nil {
+		s.Logf("Invalid cursor: %v", err)
+		return s.CreateMcpErrorResponse(ctx, ErrInvalidCursor)
+	}
+	resp := McpResourcesListResponse{
+		Resources:  page,
+		NextCursor: next,
 	}
 
 	return s.CreateMcpResponse(ctx, resp)
@@ -334,8 +759,9 @@ func (s *McpServer) MethodToolsCall(ctx context.Context, req *McpRequest) (*McpR
 		return s.CreateMcpErrorResponse(ctx, NewMcpError(ErrInvalidParametersCode, "invalid tool name", nil))
 	}
 
-	tool, ok := s.Tools[toolName]
-	if !ok {
+	tool, err := s.GetTool(toolName)
+	if err != nil || !s.toolVisible(ctx, sess, tool) {
+		// Hidden tools are reported as not found
 		return s.CreateMcpErrorResponse(ctx, NewMcpError(ErrMethodNotFoundCode, "tool not found", nil))
 	}
 
@@ -433,88 +859,109 @@ func (s *McpServer) MethodToolsCall(ctx context.Context, req *McpRequest) (*McpR
 			switch p.Type {
 			case McpToolDataTypeImage:
 				// MCP Image
-				imgRaw, ok := val.(map[string]any)
-				if !ok {
-					s.Logf("argument is not %s (actual: %s)", p.Kind, reflect.TypeOf(val))
-					return s.CreateMcpErrorResponse(ctx, NewErrInvalidArgumentType(p.Name, p.Type))
+				data, mimeType, mcpErr := s.decodeMediaArgument(p, val, "image",
+					string(McpImageMimeTypePNG), string(McpImageMimeTypeJPG), string(McpImageMimeTypeJPEG))
+				if mcpErr != nil {
+					return s.CreateMcpErrorResponse(ctx, mcpErr)
 				}
-
-				img := McpImage{}
-
-				if data, ok := imgRaw["data"]; ok {
-					if v, ok := data.(string); ok {
-						img.Data = v
-					} else {
-						s.Logf("image data is not a base64 string (actual: %s)", reflect.TypeOf(data))
-						return s.CreateMcpErrorResponse(ctx, NewErrInvalidArgumentType(p.Name, p.Type))
-					}
-				} else {
-					s.Logf("image data is missing")
-					return s.CreateMcpErrorResponse(ctx, NewErrInvalidArgumentType(p.Name, p.Type))
-				}
-
-				if mimeType, ok := imgRaw["mimeType"]; ok {
-					if v, ok := mimeType.(string); ok {
-						// Validate MIME type
-						switch McpImageMimeType(v) {
-						case McpImageMimeTypePNG,
-							McpImageMimeTypeJPG,
-							McpImageMimeTypeJPEG:
-							// Valid MIME type
-						default:
-							s.Logf("image mimeType is not supported (actual: %s)", v)
-							return s.CreateMcpErrorResponse(ctx, NewMcpError(ErrInvalidParametersCode, "image MIME type not supported", nil))
-						}
-						img.MimeType = McpImageMimeType(v)
-					} else {
-						s.Logf("image mimeType is not a string (actual: %s)", reflect.TypeOf(mimeType))
-						return s.CreateMcpErrorResponse(ctx, NewErrInvalidArgumentType(p.Name, p.Type))
-					}
-				} else {
-					s.Logf("image mimeType is missing")
-					return s.CreateMcpErrorResponse(ctx, NewErrInvalidArgumentType(p.Name, p.Type))
+				toolArgs = append(toolArgs, McpImage{MimeType: McpImageMimeType(mimeType), Data: data})
+			case McpToolDataTypeAudio:
+				// MCP Audio
+				data, mimeType, mcpErr := s.decodeMediaArgument(p, val, "audio",
+					string(McpAudioMimeTypeWAV), string(McpAudioMimeTypeMPEG), string(McpAudioMimeTypeOGG))
+				if mcpErr != nil {
+					return s.CreateMcpErrorResponse(ctx, mcpErr)
 				}
-
-				toolArgs = append(toolArgs, img)
+				toolArgs = append(toolArgs, McpAudio{MimeType: McpAudioMimeType(mimeType), Data: data})
 			}
 		default:
 			return s.CreateMcpErrorResponse(ctx, NewErrInvalidArgumentType(p.Name, p.Type))
 		}
 	}
 
+	// Check concurrency and rate limits
+	release, limitErr := s.acquireTool(sess.StableSessionID(), tool)
+	if limitErr != nil {
+		s.Logf("[%s] Tool %s rejected: %s", sess.SessionID, toolName, limitErr.Message)
+		return s.CreateMcpErrorResponse(ctx, limitErr)
+	}
+	// Tool functions still running after a timeout keep the slot until they return
+	ctx, slot := newToolSlot(ctx, release)
+	defer slot.done()
+
 	result, err := s.CallTool(ctx, toolName, toolArgs...)
 	if err != nil {
-		return nil, NewMcpError(ErrInternalErrorCode, "error calling tool", nil)
+		// Errors returned by the tool as McpError keep their code and data
+		if mcpErr, ok := asMcpError(err); ok {
+			return s.CreateMcpErrorResponse(ctx, mcpErr)
+		}
+		s.Logf("Error calling tool %s: %v", toolName, err)
+		return s.CreateMcpErrorResponse(ctx, NewErrInternalError("error calling tool", nil))
 	}
 
-	// Check if the tool returned an error
+	// Error outputs are sent as text and mark the result as an error. Other outputs are kept,
+	// so tools can return rich error results, e.g. a message with a screenshot
+	resp := McpToolCallResponse{
+		Content: make([]McpToolOutput, 0, len(result)),
+	}
 	for _, o := range result {
 		if o.Type == McpToolOutputTypeError {
-			resp := McpToolCallResponse{
-				Content: []McpToolOutput{
-					{
-						Type: McpToolOutputTypeText,
-						Text: o.Text,
-					},
-				},
-				IsError: true,
-			}
-
-			return s.CreateMcpResponse(ctx, resp)
+			o = McpToolOutput{Type: McpToolOutputTypeText, Text: o.Text}
+			resp.IsError = true
 		}
+		resp.Content = append(resp.Content, o)
 	}
 
-	resp := McpToolCallResponse{
-		Content: result,
-		IsError: false,
+	return s.CreateMcpResponse(ctx, resp)
+}
+
+// decodeMediaArgument decodes an image or audio argument with base64 encoded data and a MIME type.
+// media is the name of the media used in logs and errors, and mimeTypes are the supported MIME types.
+func (s *McpServer) decodeMediaArgument(p McpToolParameter, val any, media string, mimeTypes ...string) (string, string, *McpError) {
+	raw, ok := val.(map[string]any)
+	if !ok {
+		s.Logf("argument is not %s (actual: %s)", p.Kind, reflect.TypeOf(val))
+		return "", "", NewErrInvalidArgumentType(p.Name, p.Type)
 	}
 
-	return s.CreateMcpResponse(ctx, resp)
+	data, ok := raw["data"]
+	if !ok {
+		s.Logf("%s data is missing", media)
+		return "", "", NewErrInvalidArgumentType(p.Name, p.Type)
+	}
+	dataStr, ok := data.(string)
+	if !ok {
+		s.Logf("%s data is not a base64 string (actual: %s)", media, reflect.TypeOf(data))
+		return "", "", NewErrInvalidArgumentType(p.Name, p.Type)
+	}
+
+	mimeType, ok := raw["mimeType"]
+	if !ok {
+		s.Logf("%s mimeType is missing", media)
+		return "", "", NewErrInvalidArgumentType(p.Name, p.Type)
+	}
+	mimeTypeStr, ok := mimeType.(string)
+	if !ok {
+		s.Logf("%s mimeType is not a string (actual: %s)", media, reflect.TypeOf(mimeType))
+		return "", "", NewErrInvalidArgumentType(p.Name, p.Type)
+	}
+
+	// Validate MIME type
+	for _, t := range mimeTypes {
+		if mimeTypeStr == t {
+			return dataStr, mimeTypeStr, nil
+		}
+	}
+	s.Logf("%s mimeType is not supported (actual: %s)", media, mimeTypeStr)
+	return "", "", NewMcpError(ErrInvalidParametersCode, media+" MIME type not supported", nil)
 }
 
 // Tool functions:
 
 func (s *McpServer) GetTool(name string) (McpTool, error) {
+	s.toolsMu.RLock()
+	defer s.toolsMu.RUnlock()
+
 	if s.Tools == nil {
 		return McpTool{}, fmt.Errorf("no tools registered")
 	}
@@ -528,26 +975,142 @@ func (s *McpServer) GetTool(name string) (McpTool, error) {
 }
 
 func (s *McpServer) SetToolDescription(name string, desc string) error {
-	if t, err := s.GetTool(name); err != nil {
-		return err
-	} else {
-		t.Description = desc // Set the description of the tool
-		s.Tools[name] = t    // Update the tool in the map
-		return nil
+	return s.updateTool(name, func(t *McpTool) {
+		t.SetDescription(desc)
+	})
+}
+
+// SetToolTitle sets the human-readable title of a registered tool.
+func (s *McpServer) SetToolTitle(name string, title string) error {
+	return s.updateTool(name, func(t *McpTool) {
+		t.SetTitle(title)
+	})
+}
+
+// SetToolTimeout sets the execution time limit of a registered tool. Zero uses the ToolTimeout of the server.
+func (s *McpServer) SetToolTimeout(name string, timeout time.Duration) error {
+	return s.updateTool(name, func(t *McpTool) {
+		t.SetTimeout(timeout)
+	})
+}
+
+// SetToolAnnotations sets the behavior hints of a registered tool, which are sent to the client in tools/list.
+func (s *McpServer) SetToolAnnotations(name string, annotations McpToolAnnotations) error {
+	return s.updateTool(name, func(t *McpTool) {
+		t.SetAnnotations(annotations)
+	})
+}
+
+// updateTool applies update to a registered tool. Connected sessions are notified if the change is visible in tools/list,
+// but not for server-side configuration such as the timeout and limits.
+func (s *McpServer) updateTool(name string, update func(t *McpTool)) error {
+	s.toolsMu.Lock()
+	t, ok := s.Tools[name]
+	if !ok {
+		s.toolsMu.Unlock()
+		return fmt.Errorf("tool %s not found", name)
+	}
+	old := t
+	update(&t)
+	s.Tools[name] = t // Update the tool in the map
+	s.toolsMu.Unlock()
+
+	if t.Title != old.Title || t.Description != old.Description || !reflect.DeepEqual(t.Annotations, old.Annotations) {
+		s.notifyToolsListChanged()
+	}
+	return nil
+}
+
+// annotateToolTitles returns a copy of tools with the title moved into annotations, for protocol versions before 2025-06-18.
+func annotateToolTitles(tools []McpToolDescriptor) []McpToolDescriptor {
+	result := make([]McpToolDescriptor, len(tools))
+	for i, t := range tools {
+		if t.Title != "" {
+			annotations := McpToolAnnotations{}
+			if t.Annotations != nil {
+				annotations = *t.Annotations
+			}
+			if annotations.Title == "" {
+				annotations.Title = t.Title
+			}
+			t.Annotations = &annotations
+			t.Title = ""
+		}
+		result[i] = t
 	}
+	return result
 }
 
-// ListTools returns a list of registered tools with their input schema.
+// toolVisible reports whether the tool is visible to the session according to ToolFilter.
+func (s *McpServer) toolVisible(ctx context.Context, session McpSession, tool McpTool) bool {
+	if s.ToolFilter == nil {
+		return true
+	}
+	return s.ToolFilter(ctx, session, tool)
+}
+
+// ListTools returns a list of registered tools with their input schema. It returns an error if no tool is registered.
 func (s *McpServer) ListTools() ([]McpToolDescriptor, error) {
-	if s.Tools == nil || len(s.Tools) == 0 {
+	tools, err := s.listTools(nil)
+	if err == nil && len(tools) == 0 {
 		return nil, fmt.Errorf("no registered tools")
 	}
+	return tools, err
+}
+
+// listTools returns a list of registered tools accepted by filter. If filter is nil, all tools are returned.
+func (s *McpServer) listTools(filter func(tool McpTool) bool) ([]McpToolDescriptor, error) {
+	return describeTools(s.sortedTools(filter))
+}
+
+// toolLess reports whether tool a is listed before tool b, using ToolSorter and breaking ties by name,
+// e.g. for tools added to Tools directly which have no registration sequence.
+func (s *McpServer) toolLess(a, b McpTool) bool {
+	less := s.ToolSorter
+	if less == nil {
+		less = func(a, b McpTool) bool { return a.seq < b.seq }
+	}
+	if less(a, b) || less(b, a) {
+		return less(a, b)
+	}
+	return a.Name < b.Name
+}
 
-	tools := make([]McpToolDescriptor, 0, len(s.Tools))
+// sortedTools returns the registered tools accepted by filter in list order. If filter is nil, all tools are returned.
+func (s *McpServer) sortedTools(filter func(tool McpTool) bool) []McpTool {
+	// Snapshot the tools so filter runs without holding the lock, e.g. when it queries a session store
+	s.toolsMu.RLock()
+	snapshot := make([]McpTool, 0, len(s.Tools))
 	for _, tool := range s.Tools {
+		snapshot = append(snapshot, tool)
+	}
+	s.toolsMu.RUnlock()
+
+	// Tools may have been unregistered while serving. tools/list returns an empty list rather than an error
+	registered := make([]McpTool, 0, len(snapshot))
+	for _, tool := range snapshot {
+		if filter != nil && !filter(tool) {
+			continue
+		}
+		registered = append(registered, tool)
+	}
+
+	// Sort tools so the list is stable between requests and server instances
+	sort.Slice(registered, func(i, j int) bool {
+		return s.toolLess(registered[i], registered[j])
+	})
+	return registered
+}
+
+// describeTools returns the descriptors of tools with their input schema.
+func describeTools(registered []McpTool) ([]McpToolDescriptor, error) {
+	tools := make([]McpToolDescriptor, 0, len(registered))
+	for _, tool := range registered {
 		t := McpToolDescriptor{
 			Name:        tool.Name,
+			Title:       tool.Title,
 			Description: tool.Description,
+			Annotations: tool.Annotations,
 			InputSchema: McpToolInputSchema{
 				Type:       "object",
 				Properties: make(map[string]McpToolInputSchema),
@@ -580,6 +1143,21 @@ func (s *McpServer) ListTools() ([]McpToolDescriptor, error) {
 						},
 					},
 				}
+			case McpToolDataTypeAudio:
+				t.InputSchema.Properties[param.Name] = McpToolInputSchema{
+					Type:        "object",
+					Description: param.Description,
+					Properties: map[string]McpToolInputSchema{
+						"data": {
+							Type:        "string",
+							Description: "Base64 encoded audio data",
+						},
+						"mimeType": {
+							Type:        "string",
+							Description: "MIME type of the audio (e.g., audio/wav, audio/mpeg)",
+						},
+					},
+				}
 			default:
 				t.InputSchema.Properties[param.Name] = McpToolInputSchema{
 					Type:        string(param.Type),
@@ -596,16 +1174,169 @@ func (s *McpServer) ListTools() ([]McpToolDescriptor, error) {
 	return tools, nil
 }
 
+// ToolsHash returns a SHA-256 hash of the tool list as returned by ListTools, or of an empty list if no tool is registered.
+// The hash changes whenever a tool, its description or parameters, or the order of tools changes,
+// so it can be used by clients and caches to detect changes of the tool list.
+func (s *McpServer) ToolsHash() (string, error) {
+	tools, err := s.listTools(nil)
+	if err != nil {
+		return "", err
+	}
+	return hashTools(tools)
+}
+
+// hashTools returns a SHA-256 hash of the tool list.
+func hashTools(tools []McpToolDescriptor) (string, error) {
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode tools: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SortToolsByName is a ToolSorter listing tools in name order.
+func SortToolsByName(a, b McpTool) bool {
+	return a.Name < b.Name
+}
+
 // RegisterTool registers a tool with the server.
+// Tools can be registered while serving; connected sessions are notified with notifications/tools/list_changed.
 func (s *McpServer) RegisterTool(name string, tool any, params ...McpToolParameter) error {
+	t, err := newTool(name, tool, params...)
+	if err != nil {
+		return err
+	}
+
+	return s.addTool(t)
+}
+
+// AddTool registers a tool configured with its fields or setters, e.g. title, annotations, timeout and limits.
+// Name, Function and Parameters are validated as in RegisterTool.
+func (s *McpServer) AddTool(tool McpTool) error {
+	// Parameters may include context.Context if the tool was returned by GetTool
+	params := make([]McpToolParameter, 0, len(tool.Parameters))
+	for _, param := range tool.Parameters {
+		if param.Type != McpToolDataTypeContext {
+			params = append(params, McpToolParameter{Name: param.Name, Description: param.Description})
+		}
+	}
+	t, err := newTool(tool.Name, tool.Function, params...)
+	if err != nil {
+		return err
+	}
+	t.Title = tool.Title
+	t.Description = tool.Description
+	t.Annotations = tool.Annotations
+	t.Timeout = tool.Timeout
+	t.MaxConcurrency = tool.MaxConcurrency
+	t.RateLimit = tool.RateLimit
+
+	return s.addTool(t)
+}
+
+// addTool adds a validated tool to the end of the tool list and notifies connected sessions.
+func (s *McpServer) addTool(t McpTool) error {
+	s.toolsMu.Lock()
 	if s.Tools == nil {
 		s.Tools = map[string]McpTool{}
 	}
+	if _, ok := s.Tools[t.Name]; ok {
+		s.toolsMu.Unlock()
+		return fmt.Errorf("tool %s already registered", t.Name)
+	}
+	s.toolSeq++
+	t.seq = s.toolSeq
+	s.Tools[t.Name] = t
+	s.toolsMu.Unlock()
+
+	s.Logf("Tool registered: %v", t)
+	s.notifyToolsListChanged()
+
+	return nil
+}
+
+// ReplaceTool replaces the function and parameters of a registered tool.
+// The title, description, annotations, timeout and limits of the tool are kept.
+// Connected sessions are notified with notifications/tools/list_changed.
+func (s *McpServer) ReplaceTool(name string, tool any, params ...McpToolParameter) error {
+	t, err := newTool(name, tool, params...)
+	if err != nil {
+		return err
+	}
+
+	s.toolsMu.Lock()
+	old, ok := s.Tools[name]
+	if !ok {
+		s.toolsMu.Unlock()
+		return fmt.Errorf("tool %s not found", name)
+	}
+	// Keep the metadata and position of the tool in the list
+	t.Title = old.Title
+	t.Description = old.Description
+	t.Annotations = old.Annotations
+	t.Timeout = old.Timeout
+	t.MaxConcurrency = old.MaxConcurrency
+	t.RateLimit = old.RateLimit
+	t.seq = old.seq
+	s.Tools[name] = t
+	s.toolsMu.Unlock()
+
+	s.Logf("Tool replaced: %v", t)
+	s.notifyToolsListChanged()
+
+	return nil
+}
+
+// UnregisterTool removes a registered tool. Calls to the tool in progress are not affected.
+// Connected sessions are notified with notifications/tools/list_changed.
+func (s *McpServer) UnregisterTool(name string) error {
+	s.toolsMu.Lock()
+	if _, ok := s.Tools[name]; !ok {
+		s.toolsMu.Unlock()
+		return fmt.Errorf("tool %s not found", name)
+	}
+	delete(s.Tools, name)
+	s.toolsMu.Unlock()
 
-	if _, ok := s.Tools[name]; ok {
-		return fmt.Errorf("tool %s already registered", name)
+	s.Logf("Tool unregistered: %s", name)
+	s.notifyToolsListChanged()
+
+	return nil
+}
+
+// notifyToolsListChanged sends notifications/tools/list_changed to all connected sessions
+// if the transport is bidirectional. Notifications are sent in the background so a slow client cannot block
+// changes of the tool list, and give up after ClientRequestTimeout.
+//
+// ToolFilter is intentionally not applied: the transport only reports the stable IDs of connected sessions,
+// while the filter needs the session and its context (e.g. the session client). Sessions whose filtered list
+// did not change are notified too, and see the same list when they list the tools again.
+func (s *McpServer) notifyToolsListChanged() {
+	transport, ok := s.TransportHandler.(McpStreamTransportHandler)
+	if !ok {
+		return
 	}
 
+	timeout := s.ClientRequestTimeout
+	if timeout <= 0 {
+		timeout = DefaultClientRequestTimeout
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		for _, sid := range transport.ConnectedSessions() {
+			if err := s.SendNotification(ctx, sid, "notifications/tools/list_changed", nil); err != nil {
+				s.Logf("[%s] Cannot send tools list changed notification: %v", sid, err)
+			}
+		}
+	}()
+}
+
+// newTool creates a tool from a function. The parameters describe the function parameters other than context.Context.
+func newTool(name string, tool any, params ...McpToolParameter) (McpTool, error) {
 	t := McpTool{
 		Name:       name,
 		Function:   tool,
@@ -617,8 +1348,8 @@ func (s *McpServer) RegisterTool(name string, tool any, params ...McpToolParamet
 	contextOffset := 0
 
 	// Check validity of the tool
-	if toolInfo.Kind() != reflect.Func {
-		return fmt.Errorf("tool must be a function")
+	if toolInfo == nil || toolInfo.Kind() != reflect.Func {
+		return McpTool{}, fmt.Errorf("tool must be a function")
 	}
 
 	// Check if the number of parameters matches the function signature
@@ -631,7 +1362,7 @@ func (s *McpServer) RegisterTool(name string, tool any, params ...McpToolParamet
 
 	// Check if the number of parameters matches the function signature
 	if toolInfo.NumIn() != (len(params) + contextOffset) {
-		return fmt.Errorf("parameter count does not match the number of tool parameters")
+		return McpTool{}, fmt.Errorf("parameter count does not match the number of tool parameters")
 	}
 
 	// Setup tool parameters
@@ -665,8 +1396,11 @@ func (s *McpServer) RegisterTool(name string, tool any, params ...McpToolParamet
 			if arg == reflect.TypeOf((*McpImage)(nil)).Elem() {
 				// If the struct is McpImage, set the type accordingly
 				p.Type = McpToolDataTypeImage
+			} else if arg == reflect.TypeOf((*McpAudio)(nil)).Elem() {
+				// If the struct is McpAudio, set the type accordingly
+				p.Type = McpToolDataTypeAudio
 			} else {
-				return fmt.Errorf("unsupported struct type: %s", arg.Kind())
+				return McpTool{}, fmt.Errorf("unsupported struct type: %s", arg.Kind())
 			}
 		case reflect.Interface:
 			// Interface type
@@ -676,14 +1410,14 @@ func (s *McpServer) RegisterTool(name string, tool any, params ...McpToolParamet
 			if arg.Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
 				p.Type = McpToolDataTypeContext
 				if i != 0 {
-					return fmt.Errorf("context.Context must be the first parameter")
+					return McpTool{}, fmt.Errorf("context.Context must be the first parameter")
 				}
 			} else {
-				return fmt.Errorf("unsupported function return type: %s", arg.Kind())
+				return McpTool{}, fmt.Errorf("unsupported function return type: %s", arg.Kind())
 			}
 		default:
 			// Unsupported type
-			return fmt.Errorf("unsupported function parameter type: %s", arg.Kind())
+			return McpTool{}, fmt.Errorf("unsupported function parameter type: %s", arg.Kind())
 		}
 		t.Parameters = append(t.Parameters, p)
 	}
@@ -716,8 +1450,22 @@ func (s *McpServer) RegisterTool(name string, tool any, params ...McpToolParamet
 			if out == reflect.TypeOf((*McpImage)(nil)).Elem() {
 				// If the struct is McpImage, set the type accordingly
 				p.Type = McpToolDataTypeImage
+			} else if out == reflect.TypeOf((*McpAudio)(nil)).Elem() {
+				// If the struct is McpAudio, set the type accordingly
+				p.Type = McpToolDataTypeAudio
+			} else if out == reflect.TypeOf((*McpToolOutput)(nil)).Elem() {
+				// Content item built by the tool
+				p.Type = McpToolDataTypeContent
+			} else {
+				return McpTool{}, fmt.Errorf("unsupported struct type: %s", out.Kind())
+			}
+
+		case reflect.Slice:
+			// Slice of content items built by the tool
+			if out == reflect.TypeOf(([]McpToolOutput)(nil)) {
+				p.Type = McpToolDataTypeContent
 			} else {
-				return fmt.Errorf("unsupported struct type: %s", out.Kind())
+				return McpTool{}, fmt.Errorf("unsupported function return type: %s", out)
 			}
 
 		case reflect.Interface:
@@ -726,24 +1474,64 @@ func (s *McpServer) RegisterTool(name string, tool any, params ...McpToolParamet
 				// Error type
 				p.Type = McpToolDataTypeError
 			} else {
-				return fmt.Errorf("unsupported function return type: %s", out.Kind())
+				return McpTool{}, fmt.Errorf("unsupported function return type: %s", out.Kind())
 			}
 		default:
 			// Unsupported type
-			return fmt.Errorf("unsupported function return type: %s", out.Kind())
+			return McpTool{}, fmt.Errorf("unsupported function return type: %s", out.Kind())
 		}
 		t.Output = append(t.Output, p)
 	}
 
-	s.Tools[name] = t
+	return t, nil
+}
 
-	s.Logf("Tool registered: %v", t)
+// toolContext returns the context of a tool call limited by the timeout of the tool, or ToolTimeout if the tool has none.
+// If the incoming context has a deadline, e.g. the remaining time of a Lambda invocation, the tool must finish
+// DeadlineMargin before it so the server can still send the response.
+func (s *McpServer) toolContext(ctx context.Context, tool McpTool) (context.Context, context.CancelFunc) {
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = s.ToolTimeout
+	}
 
-	return nil
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok {
+		margin := s.DeadlineMargin
+		if margin <= 0 {
+			margin = DefaultDeadlineMargin
+		}
+		if d = d.Add(-margin); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+
+	if deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// UseToolInterceptor adds interceptors wrapping every tool call made with CallTool, including tools/call.
+// Interceptors run in the order they are added. Interceptors must be added before the server starts processing requests.
+func (s *McpServer) UseToolInterceptor(interceptors ...McpToolInterceptor) {
+	s.toolInterceptors = append(s.toolInterceptors, interceptors...)
 }
 
-// CallTool calls a registered tool with the given name and parameters.
+// CallTool calls a registered tool with the given name and parameters through the tool interceptors.
 func (s *McpServer) CallTool(ctx context.Context, name string, params ...any) ([]McpToolOutput, error) {
+	handler := McpToolHandler(s.callTool)
+	for i := len(s.toolInterceptors) - 1; i >= 0; i-- {
+		handler = s.toolInterceptors[i](handler)
+	}
+	return handler(ctx, name, params)
+}
+
+// callTool calls the function of a registered tool and converts its return values to MCP tool outputs.
+func (s *McpServer) callTool(ctx context.Context, name string, params []any) ([]McpToolOutput, error) {
 	s.Logf("Tool %s called with args: %v", name, params)
 
 	// Check if the tool is registered
@@ -762,6 +1550,10 @@ func (s *McpServer) CallTool(ctx context.Context, name string, params ...any) ([
 		return nil, fmt.Errorf("tool %s is not a function", name)
 	}
 
+	// Limit the execution time of the tool
+	ctx, cancel := s.toolContext(ctx, tool)
+	defer cancel()
+
 	// Build the arguments for the function call
 	args := make([]reflect.Value, 0, len(tool.Parameters))
 	contextOffset := 0
@@ -775,6 +1567,12 @@ func (s *McpServer) CallTool(ctx context.Context, name string, params ...any) ([
 		}
 	}
 
+	// Interceptors may change the arguments, so check their number before indexing them
+	if len(params) != len(tool.Parameters)-contextOffset {
+		s.Logf("Tool %s called with %d arguments, expected %d", name, len(params), len(tool.Parameters)-contextOffset)
+		return nil, ErrInvalidToolArguments
+	}
+
 	// Setup the rest of the arguments based on the tool parameters
 	for i, p := range tool.Parameters {
 		// Skip context parameter if it is the first parameter
@@ -802,6 +1600,13 @@ func (s *McpServer) CallTool(ctx context.Context, name string, params ...any) ([
 			}
 			args = append(args, arg)
 
+		case McpToolDataTypeAudio:
+			// Check if the parameter is of type McpAudio
+			if arg.Type() != reflect.TypeOf((*McpAudio)(nil)).Elem() {
+				return nil, fmt.Errorf("tool %s parameter %d has wrong type: expected McpAudio, got %s", name, i, arg.Type())
+			}
+			args = append(args, arg)
+
 		default:
 			// Unsupported type
 			return nil, fmt.Errorf("tool %s parameter %d has unsupported type: %s", name, i, p.Type)
@@ -811,8 +1616,42 @@ func (s *McpServer) CallTool(ctx context.Context, name string, params ...any) ([
 
 	s.Debugf("Tool %s calling function with args: %v (%d)", name, args, len(args))
 
-	// Call the function with the prepared arguments
-	out := f.Call(args)
+	// Call the function with the prepared arguments.
+	// The function runs in its own goroutine so the call can return when the deadline passes;
+	// functions accepting context.Context should stop when the context is done.
+	type toolResult struct {
+		out         []reflect.Value
+		panicOutput []McpToolOutput
+	}
+	done := make(chan toolResult, 1)
+	slot := toolSlotFromContext(ctx)
+	slot.hold()
+	go func() {
+		defer slot.done()
+		out, panicOutput := s.callToolFunction(ctx, name, f, args)
+		done <- toolResult{out, panicOutput}
+	}()
+
+	var out []reflect.Value
+	select {
+	case r := <-done:
+		if r.panicOutput != nil {
+			return r.panicOutput, nil
+		}
+		out = r.out
+	case <-ctx.Done():
+		text := fmt.Sprintf("tool %s cancelled", name)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			text = fmt.Sprintf("tool %s timed out", name)
+		}
+		s.Logf("%s: %v", text, ctx.Err())
+		return []McpToolOutput{
+			{
+				Type: McpToolOutputTypeError,
+				Text: text,
+			},
+		}, nil
+	}
 
 	// Verify the number of return values matches the tool output
 	if len(out) != len(tool.Output) {
@@ -821,12 +1660,15 @@ func (s *McpServer) CallTool(ctx context.Context, name string, params ...any) ([
 
 	// Build tool MCP output from the function return values
 	output := make([]McpToolOutput, 0, len(out))
+	// Output of return values other than zero values, kept along with an error, e.g. partial results
+	var partial []McpToolOutput
 
 	for i, o := range tool.Output {
 		if o.Kind != out[i].Kind() {
 			return nil, fmt.Errorf("tool %s return value %d has wrong type: expected %s, got %s", name, i, o.Kind, out[i].Kind())
 		}
 
+		start := len(output)
 		switch o.Type {
 		case McpToolDataTypeString,
 			McpToolDataTypeNumber,
@@ -846,26 +1688,51 @@ func (s *McpServer) CallTool(ctx context.Context, name string, params ...any) ([
 			}
 			output = append(output, mcpOut)
 
+		case McpToolDataTypeAudio:
+			// Convert the output to MCP Audio content
+			mcpOut := McpToolOutput{
+				Type:     McpToolOutputTypeAudio,
+				Data:     out[i].FieldByName("Data").String(),
+				MimeType: out[i].FieldByName("MimeType").String(),
+			}
+			output = append(output, mcpOut)
+
+		case McpToolDataTypeContent:
+			// Content items are returned as is
+			switch content := out[i].Interface().(type) {
+			case McpToolOutput:
+				output = append(output, content)
+			case []McpToolOutput:
+				output = append(output, content...)
+			}
+
 		case McpToolDataTypeError:
 			// Check if function return an error
 			if !out[i].IsNil() {
-				err := out[i].Interface()
+				err, _ := out[i].Interface().(error)
 				if err != nil {
-					// There is an error in the return value
-					// Reset the output to one single error message
-					output = []McpToolOutput{
-						{
-							Type: McpToolOutputTypeError,
-							Text: fmt.Sprint(err),
-						},
+					// Protocol errors are returned to the client as JSON-RPC errors
+					if mcpErr, ok := asToolMcpError(err); ok {
+						s.Logf("Tool %s returns MCP error: %s (code %d)", name, mcpErr.Message, mcpErr.Code)
+						return nil, err
 					}
+
+					// There is an error in the return value
+					// Keep the output returned along with the error, dropping zero values such as `return "", err`
+					output = append(partial, McpToolOutput{
+						Type: McpToolOutputTypeError,
+						Text: fmt.Sprint(err),
+					})
 					s.Logf("Tool %s returns error: %s at output position %d", name, err, i)
-					break
+					continue
 				}
 			}
 		default:
 			return nil, fmt.Errorf("tool %s return value %d has unsupported type: %s", name, i, o.Type)
 		}
+		if !out[i].IsZero() {
+			partial = append(partial, output[start:]...)
+		}
 	}
 
 	s.Logf("Tool %s returned: %v", name, output)
@@ -3,10 +3,21 @@ package mcp_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/puttsk/go-mcp"
+	"github.com/puttsk/go-mcp/session/memory"
+	"github.com/puttsk/go-mcp/transport/awslambda"
 )
 
 func simpleFunc(ctx context.Context, a, b int) int {
@@ -32,6 +43,10 @@ func imageFunc(ctx context.Context, img mcp.McpImage) (mcp.McpImage, error) {
 	return img, nil
 }
 
+func audioFunc(ctx context.Context, audio mcp.McpAudio) (mcp.McpAudio, error) {
+	return audio, nil
+}
+
 type TestTool struct {
 	Name        string
 	Description string
@@ -147,6 +162,26 @@ var testTools = map[string]TestTool{
 			},
 		},
 	},
+	"audio_func": {
+		Name:        "audio_func",
+		Description: "Function that accepts an audio clip and returns it",
+		Parameters: []mcp.McpToolParameter{
+			{Name: "audio", Description: "Audio clip"},
+		},
+		Function: audioFunc,
+		TestCases: []ToolTestCase{
+			{
+				Parameters: []any{mcp.McpAudio{Data: "base64audio", MimeType: "audio/wav"}},
+				Output: []mcp.McpToolOutput{
+					{
+						Type:     mcp.McpToolOutputTypeAudio,
+						Data:     "base64audio",
+						MimeType: "audio/wav",
+					},
+				},
+			},
+		},
+	},
 }
 
 func NewTestMcpServer() (*mcp.McpServer, error) {
@@ -184,6 +219,28 @@ func NewTestContext() context.Context {
 	return ctx
 }
 
+func TestMcpServerListToolsEmpty(t *testing.T) {
+	server, err := mcp.NewMcpServer("test_server", "1.0.0", mcp.McpProtocol2025_30_26)
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+
+	if _, err := server.ListTools(); err == nil {
+		t.Fatalf("Expected error listing tools of an empty server")
+	}
+
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	sid := initializeSession(t, server, `{"capabilities":{}}`)
+	resp, err := server.ProcessRequest(context.TODO(), newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{}}`))
+	if err != nil {
+		t.Fatalf("Failed to process request: %v", err)
+	}
+	if body := resp.(events.APIGatewayProxyResponse).Body; !strings.Contains(body, `"tools":[]`) {
+		t.Fatalf("Expected empty tool list, got %s", body)
+	}
+}
+
 func TestMcpServerRegisterTool(t *testing.T) {
 	// Test registering tools with different signatures
 
@@ -251,8 +308,8 @@ func TestMcpServerListTools(t *testing.T) {
 		t.Fatalf("Failed to list tools: %v", err)
 	}
 
-	if len(list) != 5 {
-		t.Fatalf("Expected 5 tools, got %d", len(list))
+	if len(list) != 6 {
+		t.Fatalf("Expected 6 tools, got %d", len(list))
 	}
 
 	for _, toolDesc := range list {
@@ -302,7 +359,7 @@ func TestMcpServerCallTool(t *testing.T) {
 						if out[i].Text != expected.Text {
 							t.Fatalf("Expected error message to be %s, got %s", expected.Text, out[0].Text)
 						}
-					case mcp.McpToolOutputTypeImage:
+					case mcp.McpToolOutputTypeImage, mcp.McpToolOutputTypeAudio:
 						if out[i].MimeType != expected.MimeType {
 							t.Fatalf("Expected output mime type to be %s, got %s", expected.MimeType, out[0].MimeType)
 						}
@@ -317,3 +374,2072 @@ func TestMcpServerCallTool(t *testing.T) {
 		}
 	}
 }
+
+func newLambdaRequest(method string, sessionID string, body string) events.APIGatewayProxyRequest {
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod: method,
+		Headers:    map[string]string{},
+		Body:       body,
+	}
+	if sessionID != "" {
+		req.Headers["Mcp-Session-Id"] = sessionID
+	}
+	return req
+}
+
+func TestMcpServerTerminateSession(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	sid := resp.(events.APIGatewayProxyResponse).Headers["Mcp-Session-Id"]
+	if sid == "" {
+		t.Fatalf("No session ID in initialize response")
+	}
+
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodDelete, sid, ""))
+	if err != nil {
+		t.Fatalf("Failed to terminate session: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusOK {
+		t.Fatalf("Expected status %d for session termination, got %d", http.StatusOK, code)
+	}
+
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatalf("Failed to process request: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusNotFound {
+		t.Fatalf("Expected status %d for terminated session, got %d", http.StatusNotFound, code)
+	}
+
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodDelete, sid, ""))
+	if err != nil {
+		t.Fatalf("Failed to process request: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusNotFound {
+		t.Fatalf("Expected status %d for terminating unknown session, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestSessionValueInContext(t *testing.T) {
+	manager := memory.NewSessionManager()
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	ctx := mcp.SetSessionInContext(context.TODO(), sess)
+	ctx = mcp.SetSessionManagerInContext(ctx, manager)
+
+	type selection struct {
+		Database string `json:"database"`
+	}
+
+	version, err := mcp.SetSessionValue(ctx, "selection", selection{Database: "sales"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to set session value: %v", err)
+	}
+
+	var got selection
+	if v, err := mcp.GetSessionValue(ctx, "selection", &got); err != nil || v != version {
+		t.Fatalf("Failed to get session value: %v (version %d, expected %d)", err, v, version)
+	}
+	if got.Database != "sales" {
+		t.Fatalf("Expected database to be sales, got %s", got.Database)
+	}
+
+	if _, err := mcp.SetSessionValue(ctx, "selection", selection{Database: "hr"}, 0); !errors.Is(err, mcp.ErrSessionDataVersionConflict) {
+		t.Fatalf("Expected ErrSessionDataVersionConflict, got %v", err)
+	}
+	if err := mcp.DeleteSessionValue(ctx, "selection", version); err != nil {
+		t.Fatalf("Failed to delete session value: %v", err)
+	}
+
+	// The exported context key still holds the session value
+	if got, ok := ctx.Value(mcp.McpSessionContextKey).(mcp.McpSession); !ok || got.SessionID != sess.SessionID {
+		t.Fatalf("Expected session under McpSessionContextKey, got %#v", ctx.Value(mcp.McpSessionContextKey))
+	}
+
+	// Session managers without a data store
+	ctx = mcp.SetSessionManagerInContext(ctx, basicSessionManager{manager})
+	if _, err := mcp.SetSessionValue(ctx, "selection", selection{}, 0); !errors.Is(err, mcp.ErrSessionDataNotSupported) {
+		t.Fatalf("Expected ErrSessionDataNotSupported, got %v", err)
+	}
+}
+
+// basicSessionManager hides the session data store of the wrapped session manager.
+type basicSessionManager struct {
+	mcp.McpSessionManager
+}
+
+func TestMcpServerBasicSessionManager(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = basicSessionManager{memory.NewSessionManager()}
+
+	sid := initializeSession(t, server, `{"clientInfo":{"name":"test-client","version":"1.0"}}`)
+	if sess, ok := server.SessionManager.GetSession(sid); !ok || !sess.Initialized {
+		t.Fatalf("Expected initialized session, got %#v (found: %t)", sess, ok)
+	}
+
+	// initialize does not need the session manager
+	ctx := mcp.SetSessionInContext(mcp.SetRequestIDInContext(context.TODO(), "1"), mcp.McpSession{SessionID: "test-session"})
+	resp, err := server.MethodInitialize(ctx, &mcp.McpRequest{ID: "1", Method: "initialize", Params: map[string]any{}})
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Failed to initialize without session manager: %v %v", err, resp)
+	}
+}
+
+func TestMcpServerStatusCodes(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	send := func(sid string, body string) events.APIGatewayProxyResponse {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, body))
+		if err != nil {
+			t.Fatalf("Failed to process request: %v", err)
+		}
+		return resp.(events.APIGatewayProxyResponse)
+	}
+
+	resp := send("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d for initialize, got %d", http.StatusOK, resp.StatusCode)
+	}
+	sid := resp.Headers["Mcp-Session-Id"]
+
+	resp = send(sid, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d before initialized notification, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	resp = send(sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted || resp.Body != "" {
+		t.Fatalf("Expected status %d without body for notification, got %d: %s", http.StatusAccepted, resp.StatusCode, resp.Body)
+	}
+
+	resp = send("unknown-session", `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status %d for unknown session, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp = send(sid, `not json`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for invalid request, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// Rejected notifications have no body either
+	resp = send("unknown-session", `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusNotFound || resp.Body != "" {
+		t.Fatalf("Expected status %d without body for notification, got %d: %s", http.StatusNotFound, resp.StatusCode, resp.Body)
+	}
+	resp = send(sid, `{"jsonrpc":"2.0","method":"notifications/unknown"}`)
+	if resp.StatusCode != http.StatusBadRequest || resp.Body != "" {
+		t.Fatalf("Expected status %d without body for notification, got %d: %s", http.StatusBadRequest, resp.StatusCode, resp.Body)
+	}
+}
+
+// unavailableSessionManager fails every session lookup as if the session store were down.
+type unavailableSessionManager struct {
+	mcp.McpSessionManager
+}
+
+func (m unavailableSessionManager) LookupSession(sessionID string) (mcp.McpSession, error) {
+	return mcp.McpSession{}, errors.New("session store unavailable")
+}
+
+func TestMcpServerSessionStoreError(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = unavailableSessionManager{memory.NewSessionManager()}
+
+	// Storage errors must not tell the client to drop its session
+	resp, err := server.ProcessRequest(context.TODO(), newLambdaRequest(http.MethodPost, "test-session", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatalf("Failed to process request: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d for session store error, got %d", http.StatusInternalServerError, code)
+	}
+	if mcpErr := decodeErrorResponse(t, resp); mcpErr.Code != mcp.ErrInternalErrorCode {
+		t.Fatalf("Expected internal error, got %#v", mcpErr)
+	}
+}
+
+func TestMcpServerNoSession(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	manager := memory.NewSessionManager()
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = manager
+	ctx := context.TODO()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"simple_func","arguments":{"a":1,"b":2}}}`
+
+	for _, strict := range []bool{false, true} {
+		server.StrictSession = strict
+
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, "", body))
+		if err != nil {
+			t.Fatalf("Failed to process request: %v", err)
+		}
+		awsResp := resp.(events.APIGatewayProxyResponse)
+		if awsResp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected status %d (strict: %t), got %d", http.StatusBadRequest, strict, awsResp.StatusCode)
+		}
+		if sid := awsResp.Headers["Mcp-Session-Id"]; sid != "" {
+			t.Fatalf("Unexpected session %s (strict: %t)", sid, strict)
+		}
+
+		var mcpResp mcp.McpResponse
+		if err := json.Unmarshal([]byte(awsResp.Body), &mcpResp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		expected := mcp.ErrSessionNotInitialized.Message
+		if strict {
+			expected = mcp.ErrNoSessionHeader.Message
+		}
+		if mcpResp.Error == nil || mcpResp.Error.Message != expected {
+			t.Fatalf("Expected error %q (strict: %t), got %#v", expected, strict, mcpResp.Error)
+		}
+	}
+
+	// Initialized notification without a session is accepted in non-strict mode only
+	for strict, expected := range map[bool]int{false: http.StatusAccepted, true: http.StatusBadRequest} {
+		server.StrictSession = strict
+
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, "", `{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+		if err != nil {
+			t.Fatalf("Failed to process request: %v", err)
+		}
+		if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != expected {
+			t.Fatalf("Expected status %d for initialized notification (strict: %t), got %d", expected, strict, code)
+		}
+	}
+
+	if manager.Len() != 0 {
+		t.Fatalf("Expected no session to be created, got %d", manager.Len())
+	}
+}
+
+// streamTransport is a bidirectional transport for testing. Messages sent to the client are delivered to a channel.
+type streamTransport struct {
+	awslambda.TransportHandler
+	messages chan any
+	sessions []string // Connected sessions
+}
+
+func newStreamTransport() *streamTransport {
+	return &streamTransport{messages: make(chan any, 16)}
+}
+
+func (h *streamTransport) SendMessage(ctx context.Context, sessionID string, message any) error {
+	h.messages <- message
+	return nil
+}
+
+func (h *streamTransport) ConnectedSessions() []string {
+	return h.sessions
+}
+
+// initializeSession performs the initialization handshake with the given initialize params and returns the session ID.
+func initializeSession(t *testing.T, server *mcp.McpServer, params string) string {
+	ctx := context.TODO()
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":`+params+`}`))
+	if err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	sid := resp.(events.APIGatewayProxyResponse).Headers["Mcp-Session-Id"]
+
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	if err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	// Session managers may reissue the session ID
+	if next := resp.(events.APIGatewayProxyResponse).Headers["Mcp-Session-Id"]; next != "" {
+		sid = next
+	}
+	return sid
+}
+
+// decodeToolResult decodes the tools/call result from the transport response.
+func decodeToolResult(t *testing.T, resp any) mcp.McpToolCallResponse {
+	var result struct {
+		Result mcp.McpToolCallResponse `json:"result"`
+		Error  *mcp.McpError           `json:"error"`
+	}
+	body := resp.(events.APIGatewayProxyResponse).Body
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Error != nil {
+		t.Fatalf("Unexpected error response: %s", body)
+	}
+	return result.Result
+}
+
+// decodeErrorResponse decodes the JSON-RPC error from the transport response.
+func decodeErrorResponse(t *testing.T, resp any) *mcp.McpError {
+	var result struct {
+		Error *mcp.McpError `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(resp.(events.APIGatewayProxyResponse).Body), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return result.Error
+}
+
+func summarizeFunc(ctx context.Context, text string) (string, error) {
+	result, err := mcp.CreateMessage(ctx, mcp.McpCreateMessageRequest{
+		Messages: []mcp.McpSamplingMessage{
+			{Role: "user", Content: mcp.McpToolOutput{Type: mcp.McpToolOutputTypeText, Text: "Summarize: " + text}},
+		},
+		MaxTokens: 100,
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.Content.Text, nil
+}
+
+func TestMcpServerCreateMessage(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	if err := server.RegisterTool("summarize", summarizeFunc, mcp.McpToolParameter{Name: "text"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	transport := newStreamTransport()
+	server.TransportHandler = transport
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	call := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"summarize","arguments":{"text":"long text"}}}`
+
+	// Client with sampling capability
+	sid := initializeSession(t, server, `{"capabilities":{"sampling":{}}}`)
+
+	done := make(chan any)
+	go func() {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+		if err != nil {
+			t.Errorf("Failed to call tool: %v", err)
+		}
+		done <- resp
+	}()
+
+	msg, ok := (<-transport.messages).(mcp.McpRequest)
+	if !ok || msg.Method != "sampling/createMessage" {
+		t.Fatalf("Expected sampling/createMessage request, got %#v", msg)
+	}
+
+	// Responses from other sessions are ignored
+	other := initializeSession(t, server, `{"capabilities":{"sampling":{}}}`)
+	forged := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"role":"assistant","content":{"type":"text","text":"forged"},"model":"test-model"}}`, msg.ID)
+	if _, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, other, forged)); err != nil {
+		t.Fatalf("Failed to process response: %v", err)
+	}
+
+	reply := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"role":"assistant","content":{"type":"text","text":"short text"},"model":"test-model"}}`, msg.ID)
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, reply))
+	if err != nil {
+		t.Fatalf("Failed to process response: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusAccepted {
+		t.Fatalf("Expected status %d for client response, got %d", http.StatusAccepted, code)
+	}
+
+	result := decodeToolResult(t, <-done)
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "short text" {
+		t.Fatalf("Unexpected tool result: %#v", result)
+	}
+
+	// Errors returned by the client are tool errors
+	go func() {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+		if err != nil {
+			t.Errorf("Failed to call tool: %v", err)
+		}
+		done <- resp
+	}()
+	msg, ok = (<-transport.messages).(mcp.McpRequest)
+	if !ok || msg.Method != "sampling/createMessage" {
+		t.Fatalf("Expected sampling/createMessage request, got %#v", msg)
+	}
+	reject := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-1,"message":"user rejected sampling"}}`, msg.ID)
+	if _, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, reject)); err != nil {
+		t.Fatalf("Failed to process response: %v", err)
+	}
+	result = decodeToolResult(t, <-done)
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "user rejected sampling") {
+		t.Fatalf("Expected tool error for rejected sampling, got %#v", result)
+	}
+
+	// Client did not advertise sampling
+	sid = initializeSession(t, server, `{"capabilities":{}}`)
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	result = decodeToolResult(t, resp)
+	if !result.IsError || result.Content[0].Text != mcp.ErrSamplingNotSupported.Message {
+		t.Fatalf("Expected sampling to be refused, got %#v", result)
+	}
+
+	// Client does not respond
+	server.ClientRequestTimeout = 10 * time.Millisecond
+	sid = initializeSession(t, server, `{"capabilities":{"sampling":{}}}`)
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	<-transport.messages
+	result = decodeToolResult(t, resp)
+	if !result.IsError {
+		t.Fatalf("Expected timeout error, got %#v", result)
+	}
+}
+
+func TestMcpServerRegisterPromptsList(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+
+	promptsList := func(ctx context.Context, req *mcp.McpRequest) (*mcp.McpResponse, error) {
+		return server.CreateMcpResponse(ctx, mcp.McpPromptsListResponse{Prompts: []any{"custom"}})
+	}
+	if err := server.RegisterMethod("prompts/list", promptsList); err != nil {
+		t.Fatalf("Failed to register prompts/list: %v", err)
+	}
+
+	sid := initializeSession(t, server, `{}`)
+	resp, err := server.ProcessRequest(context.TODO(), newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"prompts/list"}`))
+	if err != nil {
+		t.Fatalf("Failed to list prompts: %v", err)
+	}
+	var result struct {
+		Result mcp.McpPromptsListResponse `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(resp.(events.APIGatewayProxyResponse).Body), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Result.Prompts) != 1 || result.Result.Prompts[0] != "custom" {
+		t.Fatalf("Expected prompts of the registered method, got %#v", result.Result.Prompts)
+	}
+}
+
+func TestMcpServerSessionMiddleware(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	manager := memory.NewSessionManager()
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = manager
+	ctx := context.TODO()
+
+	var methods []string
+	authorized := false
+	server.Use(func(next mcp.McpMethodFunc) mcp.McpMethodFunc {
+		return func(ctx context.Context, req *mcp.McpRequest) (*mcp.McpResponse, error) {
+			methods = append(methods, req.Method)
+			if !authorized {
+				return server.CreateMcpErrorResponse(ctx, mcp.NewMcpError(mcp.ErrInvalidRequestCode, "unauthorized", nil).WithStatusCode(http.StatusUnauthorized))
+			}
+			return next(ctx, req)
+		}
+	})
+
+	// A rejected initialize creates no session
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("Failed to process request: %v", err)
+	}
+	if r := resp.(events.APIGatewayProxyResponse); r.StatusCode != http.StatusUnauthorized || r.Headers["Mcp-Session-Id"] != "" {
+		t.Fatalf("Expected status %d without session, got %d (session: %q)", http.StatusUnauthorized, r.StatusCode, r.Headers["Mcp-Session-Id"])
+	}
+	if n := manager.Len(); n != 0 {
+		t.Fatalf("Expected no session for rejected initialize, got %d", n)
+	}
+
+	authorized = true
+	sid := initializeSession(t, server, `{}`)
+
+	// Session termination passes through the middlewares
+	authorized = false
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodDelete, sid, ""))
+	if err != nil {
+		t.Fatalf("Failed to process request: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d for rejected termination, got %d", http.StatusUnauthorized, code)
+	}
+	if _, ok := manager.GetSession(sid); !ok {
+		t.Fatalf("Expected session to be kept after rejected termination")
+	}
+	if last := methods[len(methods)-1]; last != mcp.MethodTerminateSession {
+		t.Fatalf("Expected middleware to see %s, got %s", mcp.MethodTerminateSession, last)
+	}
+
+	authorized = true
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodDelete, sid, ""))
+	if err != nil {
+		t.Fatalf("Failed to terminate session: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusOK {
+		t.Fatalf("Expected status %d for session termination, got %d", http.StatusOK, code)
+	}
+	if _, ok := manager.GetSession(sid); ok {
+		t.Fatalf("Expected session to be deleted")
+	}
+}
+
+func TestMcpServerClientResponseMiddleware(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	if err := server.RegisterTool("summarize", summarizeFunc, mcp.McpToolParameter{Name: "text"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	transport := newStreamTransport()
+	server.TransportHandler = transport
+	server.SessionManager = memory.NewSessionManager()
+	server.ClientRequestTimeout = 50 * time.Millisecond
+	ctx := context.TODO()
+
+	// Client responses have no method
+	var responses int
+	server.Use(func(next mcp.McpMethodFunc) mcp.McpMethodFunc {
+		return func(ctx context.Context, req *mcp.McpRequest) (*mcp.McpResponse, error) {
+			if req.Method == "" {
+				responses++
+				return server.CreateMcpErrorResponse(ctx, mcp.NewMcpError(mcp.ErrInvalidRequestCode, "unauthorized", nil))
+			}
+			return next(ctx, req)
+		}
+	})
+
+	sid := initializeSession(t, server, `{"capabilities":{"sampling":{}}}`)
+	done := make(chan any)
+	go func() {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"summarize","arguments":{"text":"long text"}}}`))
+		if err != nil {
+			t.Errorf("Failed to call tool: %v", err)
+		}
+		done <- resp
+	}()
+	msg := (<-transport.messages).(mcp.McpRequest)
+
+	reply := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"role":"assistant","content":{"type":"text","text":"short text"},"model":"test-model"}}`, msg.ID)
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, reply))
+	if err != nil {
+		t.Fatalf("Failed to process response: %v", err)
+	}
+	if r := resp.(events.APIGatewayProxyResponse); r.StatusCode != http.StatusBadRequest || r.Body != "" {
+		t.Fatalf("Expected status %d without body for rejected client response, got %d: %s", http.StatusBadRequest, r.StatusCode, r.Body)
+	}
+	if responses != 1 {
+		t.Fatalf("Expected middleware to see 1 client response, got %d", responses)
+	}
+
+	// The rejected response does not complete the pending request
+	if result := decodeToolResult(t, <-done); !result.IsError {
+		t.Fatalf("Expected timeout error, got %#v", result)
+	}
+}
+
+func rootsFunc(ctx context.Context, path string) (string, error) {
+	roots, err := mcp.ListRoots(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d %t", len(roots), mcp.PathInRoots(roots, path)), nil
+}
+
+func TestMcpServerListRoots(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	if err := server.RegisterTool("roots", rootsFunc, mcp.McpToolParameter{Name: "path"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	transport := newStreamTransport()
+	server.TransportHandler = transport
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	sid := initializeSession(t, server, `{"capabilities":{"roots":{"listChanged":true}}}`)
+	call := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"roots","arguments":{"path":"/work/src/main.go"}}}`
+
+	// callTool calls the tool and answers roots/list request if expected
+	callTool := func(roots string) string {
+		done := make(chan any)
+		go func() {
+			resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+			if err != nil {
+				t.Errorf("Failed to call tool: %v", err)
+			}
+			done <- resp
+		}()
+
+		if roots != "" {
+			msg, ok := (<-transport.messages).(mcp.McpRequest)
+			if !ok || msg.Method != "roots/list" {
+				t.Fatalf("Expected roots/list request, got %#v", msg)
+			}
+			reply := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"roots":%s}}`, msg.ID, roots)
+			if _, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, reply)); err != nil {
+				t.Fatalf("Failed to process response: %v", err)
+			}
+		}
+
+		result := decodeToolResult(t, <-done)
+		if result.IsError || len(result.Content) != 1 {
+			t.Fatalf("Unexpected tool result: %#v", result)
+		}
+		return result.Content[0].Text
+	}
+
+	if got := callTool(`[{"uri":"file:///work","name":"work"},{"uri":"file:///tmp"}]`); got != "2 true" {
+		t.Fatalf("Expected 2 roots containing path, got %s", got)
+	}
+
+	// Roots are cached in the session
+	if got := callTool(""); got != "2 true" {
+		t.Fatalf("Expected cached roots, got %s", got)
+	}
+	if len(transport.messages) != 0 {
+		t.Fatalf("Unexpected request to client: %#v", <-transport.messages)
+	}
+
+	// Cache is invalidated when roots change
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`))
+	if err != nil {
+		t.Fatalf("Failed to process notification: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusAccepted {
+		t.Fatalf("Expected status %d for notification, got %d", http.StatusAccepted, code)
+	}
+	if got := callTool(`[{"uri":"file:///home/user"}]`); got != "1 false" {
+		t.Fatalf("Expected updated roots, got %s", got)
+	}
+
+	// Roots fetched before a change notification are not cached
+	listChanged := func() {
+		if _, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`)); err != nil {
+			t.Fatalf("Failed to process notification: %v", err)
+		}
+	}
+	listChanged()
+	done := make(chan any)
+	go func() {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+		if err != nil {
+			t.Errorf("Failed to call tool: %v", err)
+		}
+		done <- resp
+	}()
+	msg := (<-transport.messages).(mcp.McpRequest)
+	listChanged()
+	stale := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"roots":[{"uri":"file:///stale"}]}}`, msg.ID)
+	if _, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, stale)); err != nil {
+		t.Fatalf("Failed to process response: %v", err)
+	}
+	<-done
+	if got := callTool(`[{"uri":"file:///work"}]`); got != "1 true" {
+		t.Fatalf("Expected roots to be requested again, got %s", got)
+	}
+
+	// Notifications without a session are ignored, and uninitialized sessions are rejected
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, "", `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`))
+	if err != nil {
+		t.Fatalf("Failed to process notification: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusAccepted {
+		t.Fatalf("Expected status %d for notification without session, got %d", http.StatusAccepted, code)
+	}
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, "", `{"jsonrpc":"2.0","id":3,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	uninitialized := resp.(events.APIGatewayProxyResponse).Headers["Mcp-Session-Id"]
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, uninitialized, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`))
+	if err != nil {
+		t.Fatalf("Failed to process notification: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for notification of uninitialized session, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestPathInRoots(t *testing.T) {
+	roots := []mcp.McpRoot{{URI: "file:///work/project"}, {URI: "https://example.com/repo"}}
+
+	tests := map[string]bool{
+		"/work/project":                 true,
+		"/work/project/src/main.go":     true,
+		"file:///work/project/go.mod":   true,
+		"/work/project/../secret":       false,
+		"/work/project-other/file":      false,
+		"/work":                         false,
+		"src/main.go":                   false,
+		"https://example.com/repo/file": false,
+		"file://server/work/project":    false,
+	}
+	if runtime.GOOS == "windows" {
+		// Paths are absolute only with a drive letter
+		roots = []mcp.McpRoot{{URI: "file:///C:/work/project"}}
+		tests = map[string]bool{
+			`C:\work\project\src\main.go`:    true,
+			"file:///C:/work/project/go.mod": true,
+			`C:\work\project\..\secret`:      false,
+			`D:\work\project\file`:           false,
+		}
+	}
+	for path, expected := range tests {
+		if got := mcp.PathInRoots(roots, path); got != expected {
+			t.Errorf("PathInRoots(%q) = %t, expected %t", path, got, expected)
+		}
+	}
+}
+
+func TestPathInRootsResolved(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{root, outside} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("Symbolic links not supported: %v", err)
+	}
+	roots := []mcp.McpRoot{{URI: "file://" + filepath.ToSlash(root)}}
+
+	tests := map[string]bool{
+		filepath.Join(root, "new.txt"):          true,
+		filepath.Join(root, "sub", "new.txt"):   true,
+		filepath.Join(root, "link", "file.txt"): false,
+	}
+	for path, expected := range tests {
+		got, err := mcp.PathInRootsResolved(roots, path)
+		if err != nil {
+			t.Fatalf("Failed to resolve %s: %v", path, err)
+		}
+		if got != expected {
+			t.Errorf("PathInRootsResolved(%q) = %t, expected %t", path, got, expected)
+		}
+	}
+
+	// Lexical check is fooled by the link
+	if !mcp.PathInRoots(roots, filepath.Join(root, "link", "file.txt")) {
+		t.Fatalf("Expected lexical check to accept the link")
+	}
+}
+
+type deleteConfirmation struct {
+	Confirm bool   `json:"confirm" description:"Confirm deletion"`
+	Reason  string `json:"reason,omitempty" description:"Reason for deletion"`
+}
+
+func deleteFileFunc(ctx context.Context, path string) (string, error) {
+	var confirmation deleteConfirmation
+	action, err := mcp.Elicit(ctx, "Delete "+path+"?", &confirmation)
+	if err != nil {
+		return "", err
+	}
+	if action != mcp.McpElicitActionAccept || !confirmation.Confirm {
+		return "not deleted: " + string(action), nil
+	}
+	return "deleted: " + confirmation.Reason, nil
+}
+
+func TestMcpServerElicit(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	if err := server.RegisterTool("delete_file", deleteFileFunc, mcp.McpToolParameter{Name: "path"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	transport := newStreamTransport()
+	server.TransportHandler = transport
+	server.SessionManager = memory.NewSessionManager()
+	server.ProtocolVersion = mcp.McpProtocol2025_06_18
+	ctx := context.TODO()
+
+	sid := initializeSession(t, server, `{"protocolVersion":"2025-06-18","capabilities":{"elicitation":{}}}`)
+	call := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"delete_file","arguments":{"path":"/tmp/file"}}}`
+
+	// callTool calls the tool and answers elicitation/create request with result
+	callTool := func(result string) string {
+		done := make(chan any)
+		go func() {
+			resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+			if err != nil {
+				t.Errorf("Failed to call tool: %v", err)
+			}
+			done <- resp
+		}()
+
+		msg, ok := (<-transport.messages).(mcp.McpRequest)
+		if !ok || msg.Method != "elicitation/create" {
+			t.Fatalf("Expected elicitation/create request, got %#v", msg)
+		}
+		params, ok := msg.Params.(mcp.McpElicitRequest)
+		if !ok || params.Message != "Delete /tmp/file?" {
+			t.Fatalf("Unexpected elicitation request: %#v", msg.Params)
+		}
+
+		reply := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, msg.ID, result)
+		if _, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, reply)); err != nil {
+			t.Fatalf("Failed to process response: %v", err)
+		}
+
+		toolResult := decodeToolResult(t, <-done)
+		if toolResult.IsError || len(toolResult.Content) != 1 {
+			t.Fatalf("Unexpected tool result: %#v", toolResult)
+		}
+		return toolResult.Content[0].Text
+	}
+
+	if got := callTool(`{"action":"accept","content":{"confirm":true,"reason":"cleanup"}}`); got != "deleted: cleanup" {
+		t.Fatalf("Expected accepted elicitation, got %s", got)
+	}
+	if got := callTool(`{"action":"decline"}`); got != "not deleted: decline" {
+		t.Fatalf("Expected declined elicitation, got %s", got)
+	}
+	if got := callTool(`{"action":"cancel"}`); got != "not deleted: cancel" {
+		t.Fatalf("Expected cancelled elicitation, got %s", got)
+	}
+
+	// Client did not advertise elicitation, or negotiated a protocol version without elicitation
+	for _, params := range []string{
+		`{"protocolVersion":"2025-06-18","capabilities":{}}`,
+		`{"protocolVersion":"2025-03-26","capabilities":{"elicitation":{}}}`,
+	} {
+		sid = initializeSession(t, server, params)
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		if result := decodeToolResult(t, resp); !result.IsError || result.Content[0].Text != mcp.ErrElicitationNotSupported.Message {
+			t.Fatalf("Expected elicitation to be refused for %s, got %#v", params, result)
+		}
+	}
+}
+
+func TestMcpServerProtocolVersion(t *testing.T) {
+	server, err := mcp.NewMcpServer("test_server", "1.0.0", mcp.McpProtocol2025_06_18)
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+
+	tests := map[string]mcp.McpProtocolVersion{
+		`"2025-03-26"`: mcp.McpProtocol2025_30_26,
+		`"2025-06-18"`: mcp.McpProtocol2025_06_18,
+		`"2099-01-01"`: mcp.McpProtocol2025_06_18,
+	}
+	for requested, expected := range tests {
+		resp, err := server.ProcessRequest(context.TODO(), newLambdaRequest(http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":`+requested+`}}`))
+		if err != nil {
+			t.Fatalf("Failed to initialize: %v", err)
+		}
+		var result struct {
+			Result mcp.McpInitializeResponse `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(resp.(events.APIGatewayProxyResponse).Body), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result.Result.ProtocolVersion != expected {
+			t.Fatalf("Expected protocol version %s for %s, got %s", expected, requested, result.Result.ProtocolVersion)
+		}
+	}
+}
+
+func TestElicitSchema(t *testing.T) {
+	schema, err := mcp.ElicitSchema(struct {
+		Name    string   `json:"name" description:"Your name"`
+		Age     int      `json:"age"`
+		Score   *float64 `json:"score"`
+		Agree   bool
+		Ignored string `json:"-"`
+	}{})
+	if err != nil {
+		t.Fatalf("Failed to generate schema: %v", err)
+	}
+
+	if schema.Type != "object" || len(schema.Properties) != 4 {
+		t.Fatalf("Unexpected schema: %#v", schema)
+	}
+	if p := schema.Properties["name"]; p.Type != "string" || p.Description != "Your name" {
+		t.Fatalf("Unexpected name property: %#v", p)
+	}
+	if p := schema.Properties["age"]; p.Type != "number" {
+		t.Fatalf("Unexpected age property: %#v", p)
+	}
+	if p := schema.Properties["Agree"]; p.Type != "boolean" {
+		t.Fatalf("Unexpected Agree property: %#v", p)
+	}
+	if strings.Join(schema.Required, ",") != "name,age,Agree" {
+		t.Fatalf("Unexpected required properties: %v", schema.Required)
+	}
+
+	if _, err := mcp.ElicitSchema(struct{ Tags []string }{}); err == nil {
+		t.Fatalf("Expected error for unsupported field type")
+	}
+}
+
+func TestMcpServerDynamicTools(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	transport := newStreamTransport()
+	server.TransportHandler = transport
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if body := resp.(events.APIGatewayProxyResponse).Body; !strings.Contains(body, `"tools":{"listChanged":true}`) {
+		t.Fatalf("Expected tools listChanged capability, got %s", body)
+	}
+
+	sid := initializeSession(t, server, `{"capabilities":{}}`)
+	transport.sessions = []string{sid}
+
+	// expectListChanged checks that connected sessions were notified
+	expectListChanged := func() {
+		t.Helper()
+		select {
+		case msg := <-transport.messages:
+			n, ok := msg.(mcp.McpNotification)
+			if !ok || n.Method != "notifications/tools/list_changed" {
+				t.Fatalf("Expected tools list changed notification, got %#v", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected tools list changed notification")
+		}
+	}
+
+	callTool := func(name string) mcp.McpToolCallResponse {
+		t.Helper()
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"`+name+`","arguments":{"a":1,"b":2}}}`))
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		return decodeToolResult(t, resp)
+	}
+
+	if err := server.RegisterTool("add", simpleFunc, mcp.McpToolParameter{Name: "a"}, mcp.McpToolParameter{Name: "b"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	expectListChanged()
+	if result := callTool("add"); result.Content[0].Text != "3" {
+		t.Fatalf("Unexpected tool result: %#v", result)
+	}
+
+	if err := server.ReplaceTool("add", func(a, b int) int { return a + b + 10 }, mcp.McpToolParameter{Name: "a"}, mcp.McpToolParameter{Name: "b"}); err != nil {
+		t.Fatalf("Failed to replace tool: %v", err)
+	}
+	expectListChanged()
+	if result := callTool("add"); result.Content[0].Text != "13" {
+		t.Fatalf("Unexpected tool result after replace: %#v", result)
+	}
+
+	// Metadata changes are notified, but not server-side configuration
+	if err := server.SetToolTimeout("add", time.Second); err != nil {
+		t.Fatalf("Failed to set tool timeout: %v", err)
+	}
+	if err := server.SetToolDescription("add", "Adds two numbers"); err != nil {
+		t.Fatalf("Failed to set tool description: %v", err)
+	}
+	expectListChanged()
+	select {
+	case msg := <-transport.messages:
+		t.Fatalf("Expected a single notification, got %#v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := server.UnregisterTool("add"); err != nil {
+		t.Fatalf("Failed to unregister tool: %v", err)
+	}
+	expectListChanged()
+	if _, err := server.GetTool("add"); err == nil {
+		t.Fatalf("Expected tool to be unregistered")
+	}
+	if err := server.UnregisterTool("add"); err == nil {
+		t.Fatalf("Expected error unregistering unknown tool")
+	}
+	if err := server.ReplaceTool("add", simpleFunc, mcp.McpToolParameter{Name: "a"}, mcp.McpToolParameter{Name: "b"}); err == nil {
+		t.Fatalf("Expected error replacing unknown tool")
+	}
+}
+
+func TestMcpServerToolFilter(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	// Only admin clients can see error_func
+	server.ToolFilter = func(ctx context.Context, session mcp.McpSession, tool mcp.McpTool) bool {
+		client, err := mcp.GetSessionClientFromContext(ctx)
+		if err != nil {
+			return false
+		}
+		return tool.Name != "error_func" || client.ClientInfo.Name == "admin"
+	}
+
+	visibleTools := func(sid string) map[string]bool {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+		if err != nil {
+			t.Fatalf("Failed to list tools: %v", err)
+		}
+		var result struct {
+			Result mcp.McpToolsListResponse `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(resp.(events.APIGatewayProxyResponse).Body), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		names := map[string]bool{}
+		for _, tool := range result.Result.Tools {
+			names[tool.Name] = true
+		}
+		return names
+	}
+	callErrorFunc := func(sid string) *mcp.McpError {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"error_func","arguments":{"a":1,"b":2}}}`))
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		var result struct {
+			Error *mcp.McpError `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(resp.(events.APIGatewayProxyResponse).Body), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result.Error
+	}
+
+	admin := initializeSession(t, server, `{"clientInfo":{"name":"admin","version":"1.0"}}`)
+	if tools := visibleTools(admin); len(tools) != len(testTools) || !tools["error_func"] {
+		t.Fatalf("Expected all tools for admin, got %v", tools)
+	}
+	if err := callErrorFunc(admin); err != nil {
+		t.Fatalf("Expected admin to call error_func, got %v", err)
+	}
+
+	user := initializeSession(t, server, `{"clientInfo":{"name":"user","version":"1.0"}}`)
+	if tools := visibleTools(user); len(tools) != len(testTools)-1 || tools["error_func"] {
+		t.Fatalf("Expected error_func to be hidden, got %v", tools)
+	}
+	if err := callErrorFunc(user); err == nil || err.Code != mcp.ErrMethodNotFoundCode {
+		t.Fatalf("Expected hidden tool not to be callable, got %v", err)
+	}
+
+	// The filter runs without the registry lock, so it can update the tools
+	server.ToolFilter = func(ctx context.Context, session mcp.McpSession, tool mcp.McpTool) bool {
+		return server.SetToolDescription(tool.Name, "Filtered "+tool.Name) == nil
+	}
+	if tools := visibleTools(user); len(tools) != len(testTools) {
+		t.Fatalf("Expected all tools, got %v", tools)
+	}
+}
+
+func TestMcpServerPagination(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	server.PageSize = 2
+	server.Prompts = []any{"p1", "p2", "p3"}
+	ctx := context.TODO()
+
+	sid := initializeSession(t, server, `{}`)
+
+	// listPage requests a page of the list method and decodes the result into v
+	listPage := func(method string, cursor string, v any) *mcp.McpError {
+		params := "{}"
+		if cursor != "" {
+			params = `{"cursor":"` + cursor + `"}`
+		}
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"`+method+`","params":`+params+`}`))
+		if err != nil {
+			t.Fatalf("Failed to process %s: %v", method, err)
+		}
+		result := struct {
+			Result any           `json:"result"`
+			Error  *mcp.McpError `json:"error"`
+		}{Result: v}
+		if err := json.Unmarshal([]byte(resp.(events.APIGatewayProxyResponse).Body), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result.Error
+	}
+
+	names := []string{}
+	cursor := ""
+	pages := 0
+	for {
+		var page mcp.McpToolsListResponse
+		if err := listPage("tools/list", cursor, &page); err != nil {
+			t.Fatalf("Failed to list tools: %v", err)
+		}
+		if len(page.Tools) > server.PageSize {
+			t.Fatalf("Page exceeds page size: %d", len(page.Tools))
+		}
+		for _, tool := range page.Tools {
+			names = append(names, tool.Name)
+		}
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if pages != 3 || len(names) != len(testTools) {
+		t.Fatalf("Expected %d tools in 3 pages, got %v in %d pages", len(testTools), names, pages)
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			t.Fatalf("Tool %s listed twice: %v", name, names)
+		}
+		seen[name] = true
+	}
+
+	// Removing a listed tool does not shift the following pages
+	var first mcp.McpToolsListResponse
+	if err := listPage("tools/list", "", &first); err != nil {
+		t.Fatalf("Failed to list tools: %v", err)
+	}
+	if hash, err := server.ToolsHash(); err != nil || first.Meta[mcp.McpMetaToolsHash] != hash {
+		t.Fatalf("Expected tools hash %s in _meta, got %v (error: %v)", hash, first.Meta, err)
+	}
+	if err := server.UnregisterTool(first.Tools[0].Name); err != nil {
+		t.Fatalf("Failed to unregister tool: %v", err)
+	}
+	var second mcp.McpToolsListResponse
+	if err := listPage("tools/list", first.NextCursor, &second); err != nil {
+		t.Fatalf("Failed to list tools: %v", err)
+	}
+	if len(second.Tools) == 0 || second.Tools[0].Name != names[2] {
+		t.Fatalf("Expected second page to start at %s, got %#v", names[2], second.Tools)
+	}
+
+	// Removing the last tool of the page resumes after its position
+	if err := server.UnregisterTool(first.Tools[1].Name); err != nil {
+		t.Fatalf("Failed to unregister tool: %v", err)
+	}
+	second = mcp.McpToolsListResponse{}
+	if err := listPage("tools/list", first.NextCursor, &second); err != nil {
+		t.Fatalf("Failed to list tools after removing the cursor tool: %v", err)
+	}
+	if len(second.Tools) == 0 || second.Tools[0].Name != names[2] {
+		t.Fatalf("Expected second page to start at %s, got %#v", names[2], second.Tools)
+	}
+
+	// Also with a ToolSorter
+	server.ToolSorter = mcp.SortToolsByName
+	first = mcp.McpToolsListResponse{}
+	if err := listPage("tools/list", "", &first); err != nil {
+		t.Fatalf("Failed to list tools: %v", err)
+	}
+	all, err := server.ListTools()
+	if err != nil {
+		t.Fatalf("Failed to list tools: %v", err)
+	}
+	if err := server.UnregisterTool(first.Tools[1].Name); err != nil {
+		t.Fatalf("Failed to unregister tool: %v", err)
+	}
+	second = mcp.McpToolsListResponse{}
+	if err := listPage("tools/list", first.NextCursor, &second); err != nil {
+		t.Fatalf("Failed to list tools after removing the cursor tool: %v", err)
+	}
+	if len(second.Tools) == 0 || second.Tools[0].Name != all[2].Name {
+		t.Fatalf("Expected second page to start at %s, got %#v", all[2].Name, second.Tools)
+	}
+	server.ToolSorter = nil
+
+	if err := listPage("tools/list", "cG9zOng", &mcp.McpToolsListResponse{}); err == nil || err.Code != mcp.ErrInvalidParametersCode {
+		t.Fatalf("Expected invalid cursor error for malformed position, got %v", err)
+	}
+
+	var prompts mcp.McpPromptsListResponse
+	if err := listPage("prompts/list", "", &prompts); err != nil || len(prompts.Prompts) != 2 || prompts.NextCursor == "" {
+		t.Fatalf("Unexpected first page of prompts: %#v (error: %v)", prompts, err)
+	}
+	next := prompts.NextCursor
+	prompts = mcp.McpPromptsListResponse{}
+	if err := listPage("prompts/list", next, &prompts); err != nil || len(prompts.Prompts) != 1 || prompts.NextCursor != "" {
+		t.Fatalf("Unexpected last page of prompts: %#v (error: %v)", prompts, err)
+	}
+
+	var resources mcp.McpResourcesListResponse
+	if err := listPage("resources/list", "", &resources); err != nil || len(resources.Resources) != 0 || resources.NextCursor != "" {
+		t.Fatalf("Unexpected resources: %#v (error: %v)", resources, err)
+	}
+
+	if err := listPage("tools/list", "not-a-cursor", &mcp.McpToolsListResponse{}); err == nil || err.Code != mcp.ErrInvalidParametersCode {
+		t.Fatalf("Expected invalid cursor error, got %v", err)
+	}
+}
+
+func TestMcpServerToolOrder(t *testing.T) {
+	newServer := func() *mcp.McpServer {
+		server, err := mcp.NewMcpServer("test_server", "1.0.0", mcp.McpProtocol2025_30_26)
+		if err != nil {
+			t.Fatalf("Failed to create MCP server: %v", err)
+		}
+		for _, name := range []string{"zeta", "alpha", "mid"} {
+			if err := server.RegisterTool(name, simpleFunc, mcp.McpToolParameter{Name: "a"}, mcp.McpToolParameter{Name: "b"}); err != nil {
+				t.Fatalf("Failed to register tool: %v", err)
+			}
+		}
+		return server
+	}
+	toolNames := func(server *mcp.McpServer) string {
+		tools, err := server.ListTools()
+		if err != nil {
+			t.Fatalf("Failed to list tools: %v", err)
+		}
+		names := make([]string, len(tools))
+		for i, tool := range tools {
+			names[i] = tool.Name
+		}
+		return strings.Join(names, ",")
+	}
+	toolsHash := func(server *mcp.McpServer) string {
+		hash, err := server.ToolsHash()
+		if err != nil {
+			t.Fatalf("Failed to hash tools: %v", err)
+		}
+		return hash
+	}
+
+	server := newServer()
+	for range 10 {
+		if got := toolNames(server); got != "zeta,alpha,mid" {
+			t.Fatalf("Expected registration order, got %s", got)
+		}
+	}
+
+	// Replaced tools keep their position
+	if err := server.ReplaceTool("zeta", scalarTypeFunc, mcp.McpToolParameter{Name: "a"}, mcp.McpToolParameter{Name: "b"}, mcp.McpToolParameter{Name: "c"}); err != nil {
+		t.Fatalf("Failed to replace tool: %v", err)
+	}
+	if got := toolNames(server); got != "zeta,alpha,mid" {
+		t.Fatalf("Expected replaced tool to keep its position, got %s", got)
+	}
+
+	server.ToolSorter = mcp.SortToolsByName
+	if got := toolNames(server); got != "alpha,mid,zeta" {
+		t.Fatalf("Expected name order, got %s", got)
+	}
+
+	// Ties are broken by name, e.g. for tools added without RegisterTool
+	server.ToolSorter = nil
+	server.Tools["beta"] = mcp.McpTool{Name: "beta"}
+	server.Tools["alef"] = mcp.McpTool{Name: "alef"}
+	for range 10 {
+		if got := toolNames(server); got != "alef,beta,zeta,alpha,mid" {
+			t.Fatalf("Expected unsequenced tools in name order, got %s", got)
+		}
+	}
+
+	// Hash is stable across servers and changes with the tool list
+	hash := toolsHash(newServer())
+	if toolsHash(newServer()) != hash {
+		t.Fatalf("Expected same hash for same tools")
+	}
+	other := newServer()
+	if err := other.SetToolDescription("mid", "Changed"); err != nil {
+		t.Fatalf("Failed to set tool description: %v", err)
+	}
+	if toolsHash(other) == hash {
+		t.Fatalf("Expected hash to change with tool description")
+	}
+	other = newServer()
+	other.ToolSorter = mcp.SortToolsByName
+	if toolsHash(other) == hash {
+		t.Fatalf("Expected hash to change with tool order")
+	}
+}
+
+func TestMcpServerToolAnnotations(t *testing.T) {
+	server, err := mcp.NewMcpServer("test_server", "1.0.0", mcp.McpProtocol2025_06_18)
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+
+	if err := server.RegisterTool("read_file", func(path string) string { return path }, mcp.McpToolParameter{Name: "path"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := server.SetToolTitle("read_file", "Read File"); err != nil {
+		t.Fatalf("Failed to set tool title: %v", err)
+	}
+	if err := server.SetToolAnnotations("read_file", mcp.McpToolAnnotations{ReadOnlyHint: mcp.Bool(true), OpenWorldHint: mcp.Bool(false)}); err != nil {
+		t.Fatalf("Failed to set tool annotations: %v", err)
+	}
+	if err := server.SetToolAnnotations("unknown", mcp.McpToolAnnotations{}); err == nil {
+		t.Fatalf("Expected error setting annotations of unknown tool")
+	}
+
+	// Metadata is kept when the tool is replaced
+	if err := server.ReplaceTool("read_file", func(path string) string { return "new " + path }, mcp.McpToolParameter{Name: "path"}); err != nil {
+		t.Fatalf("Failed to replace tool: %v", err)
+	}
+
+	// Configured tools can be registered with AddTool
+	tool := mcp.McpTool{Name: "delete_file", Function: func(path string) error { return nil }, Parameters: []mcp.McpToolParameter{{Name: "path"}}}
+	tool.SetTitle("Delete File").SetAnnotations(mcp.McpToolAnnotations{DestructiveHint: mcp.Bool(true)})
+	if err := server.AddTool(tool); err != nil {
+		t.Fatalf("Failed to add tool: %v", err)
+	}
+	if err := server.AddTool(mcp.McpTool{Name: "no_function"}); err == nil {
+		t.Fatalf("Expected error adding tool without function")
+	}
+
+	listTools := func(protocolVersion string) string {
+		sid := initializeSession(t, server, `{"protocolVersion":"`+protocolVersion+`"}`)
+		resp, err := server.ProcessRequest(context.TODO(), newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+		if err != nil {
+			t.Fatalf("Failed to list tools: %v", err)
+		}
+		return resp.(events.APIGatewayProxyResponse).Body
+	}
+
+	body := listTools("2025-06-18")
+	for _, expected := range []string{`"title":"Read File"`, `"annotations":{"readOnlyHint":true,"openWorldHint":false}`, `"title":"Delete File"`, `"annotations":{"destructiveHint":true}`} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected %s in tools/list response, got %s", expected, body)
+		}
+	}
+
+	// Clients before 2025-06-18 get the title in annotations
+	body = listTools("2025-03-26")
+	for _, expected := range []string{`"annotations":{"title":"Read File","readOnlyHint":true,"openWorldHint":false}`, `"annotations":{"title":"Delete File","destructiveHint":true}`} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected %s in tools/list response, got %s", expected, body)
+		}
+	}
+	if strings.Contains(body, `"title":"Read File","description"`) {
+		t.Fatalf("Expected no top-level title for 2025-03-26, got %s", body)
+	}
+
+	// The registered tool is not changed
+	if registered, err := server.GetTool("read_file"); err != nil || registered.Annotations.Title != "" {
+		t.Fatalf("Unexpected registered tool: %#v (error: %v)", registered, err)
+	}
+}
+
+func TestMcpServerMiddleware(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	var calls []string
+	trace := func(name string) mcp.McpMiddleware {
+		return func(next mcp.McpMethodFunc) mcp.McpMethodFunc {
+			return func(ctx context.Context, req *mcp.McpRequest) (*mcp.McpResponse, error) {
+				calls = append(calls, name+">"+req.Method)
+				resp, err := next(ctx, req)
+				calls = append(calls, name+"<"+req.Method)
+				return resp, err
+			}
+		}
+	}
+	errUnauthorized := mcp.NewMcpError(mcp.ErrInvalidRequestCode, "unauthorized", nil)
+	auth := func(next mcp.McpMethodFunc) mcp.McpMethodFunc {
+		return func(ctx context.Context, req *mcp.McpRequest) (*mcp.McpResponse, error) {
+			if req.Method == "tools/call" {
+				client, err := mcp.GetSessionClientFromContext(ctx)
+				if err != nil || client.ClientInfo.Name != "trusted" {
+					return server.CreateMcpErrorResponse(ctx, errUnauthorized)
+				}
+			}
+			return next(ctx, req)
+		}
+	}
+	server.Use(trace("outer"), trace("inner"))
+	server.Use(auth)
+
+	sid := initializeSession(t, server, `{"clientInfo":{"name":"trusted","version":"1.0"}}`)
+	expected := "outer>initialize,inner>initialize,inner<initialize,outer<initialize," +
+		"outer>notifications/initialized,inner>notifications/initialized,inner<notifications/initialized,outer<notifications/initialized"
+	if got := strings.Join(calls, ","); got != expected {
+		t.Fatalf("Unexpected middleware order: %s", got)
+	}
+
+	call := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"simple_func","arguments":{"a":1,"b":2}}}`
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	if result := decodeToolResult(t, resp); result.Content[0].Text != "3" {
+		t.Fatalf("Unexpected tool result: %#v", result)
+	}
+
+	// Middleware short-circuits the call
+	sid = initializeSession(t, server, `{"clientInfo":{"name":"untrusted","version":"1.0"}}`)
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, call))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	if body := resp.(events.APIGatewayProxyResponse).Body; !strings.Contains(body, `"message":"unauthorized"`) {
+		t.Fatalf("Expected unauthorized error, got %s", body)
+	}
+}
+
+func TestMcpServerToolInterceptor(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	ctx := NewTestContext()
+
+	// Double the first argument of simple_func
+	server.UseToolInterceptor(func(next mcp.McpToolHandler) mcp.McpToolHandler {
+		return func(ctx context.Context, name string, args []any) ([]mcp.McpToolOutput, error) {
+			if name == "simple_func" {
+				args = append([]any{args[0].(int) * 2}, args[1:]...)
+			}
+			return next(ctx, name, args)
+		}
+	})
+
+	// Cache results of simple_func
+	cache := map[string][]mcp.McpToolOutput{}
+	calls := 0
+	server.UseToolInterceptor(func(next mcp.McpToolHandler) mcp.McpToolHandler {
+		return func(ctx context.Context, name string, args []any) ([]mcp.McpToolOutput, error) {
+			key := fmt.Sprint(name, args)
+			if output, ok := cache[key]; ok {
+				return output, nil
+			}
+			calls++
+			output, err := next(ctx, name, args)
+			if err == nil {
+				cache[key] = output
+			}
+			return output, err
+		}
+	})
+
+	// Redact secrets from text outputs
+	server.UseToolInterceptor(func(next mcp.McpToolHandler) mcp.McpToolHandler {
+		return func(ctx context.Context, name string, args []any) ([]mcp.McpToolOutput, error) {
+			output, err := next(ctx, name, args)
+			for i := range output {
+				output[i].Text = strings.ReplaceAll(output[i].Text, "String: secret", "String: [REDACTED]")
+			}
+			return output, err
+		}
+	})
+
+	for range 2 {
+		output, err := server.CallTool(ctx, "simple_func", 2, 3)
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		if output[0].Text != "7" {
+			t.Fatalf("Expected changed argument, got %v", output)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected cached result on second call, got %d calls", calls)
+	}
+
+	output, err := server.CallTool(ctx, "scalar_type_func", "secret", 1.0, true)
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	if strings.Contains(output[0].Text, "secret") {
+		t.Fatalf("Expected redacted output, got %v", output)
+	}
+
+	// Interceptors dropping arguments get an invalid params error rather than a panic
+	server.UseToolInterceptor(func(next mcp.McpToolHandler) mcp.McpToolHandler {
+		return func(ctx context.Context, name string, args []any) ([]mcp.McpToolOutput, error) {
+			if name == "context_func" {
+				args = args[:1]
+			}
+			return next(ctx, name, args)
+		}
+	})
+	_, err = server.CallTool(ctx, "context_func", 1, 2)
+	var mcpErr *mcp.McpError
+	if !errors.As(err, &mcpErr) || mcpErr.Code != mcp.ErrInvalidParametersCode {
+		t.Fatalf("Expected invalid params error, got %v", err)
+	}
+}
+
+func TestMcpServerPanicRecovery(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	server.ExposePanics = true
+	var panics []mcp.McpPanic
+	server.PanicHandler = func(ctx context.Context, p mcp.McpPanic) {
+		panics = append(panics, p)
+	}
+	if err := server.RegisterTool("panic_tool", func(a int) int { panic("tool failure") }, mcp.McpToolParameter{Name: "a"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := server.RegisterMethod("test/panic", func(ctx context.Context, req *mcp.McpRequest) (*mcp.McpResponse, error) {
+		panic("method failure")
+	}); err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+
+	sid := initializeSession(t, server, `{}`)
+
+	// Tool panics are returned as tool errors
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"panic_tool","arguments":{"a":1}}}`))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	result := decodeToolResult(t, resp)
+	if !result.IsError || !strings.HasPrefix(result.Content[0].Text, "tool panic_tool panicked: tool failure") {
+		t.Fatalf("Expected tool error with panic value, got %#v", result)
+	}
+	if len(panics) != 1 || panics[0].Tool != "panic_tool" || panics[0].Value != "tool failure" || len(panics[0].Stack) == 0 {
+		t.Fatalf("Unexpected reported panics: %#v", panics)
+	}
+
+	// Method panics are returned as internal errors
+	methodPanic := func() *mcp.McpError {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":3,"method":"test/panic"}`))
+		if err != nil {
+			t.Fatalf("Failed to process request: %v", err)
+		}
+		var result struct {
+			Error *mcp.McpError `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(resp.(events.APIGatewayProxyResponse).Body), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result.Error == nil || result.Error.Code != mcp.ErrInternalErrorCode {
+			t.Fatalf("Expected internal error, got %#v", result.Error)
+		}
+		return result.Error
+	}
+
+	if data, ok := methodPanic().Data.(map[string]any); !ok || data["panic"] != "method failure" || data["stack"] == "" {
+		t.Fatalf("Expected panic and stack trace with ExposePanics")
+	}
+	if len(panics) != 2 || panics[1].Method != "test/panic" {
+		t.Fatalf("Unexpected reported panics: %#v", panics)
+	}
+
+	// Debug logging does not expose panics to the client
+	server.ExposePanics = false
+	server.LogLevel = mcp.LogLevelDebug
+	if data := methodPanic().Data; data != nil {
+		t.Fatalf("Expected no stack trace without ExposePanics, got %v", data)
+	}
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"panic_tool","arguments":{"a":1}}}`))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	if result := decodeToolResult(t, resp); !result.IsError || result.Content[0].Text != "tool panic_tool panicked" {
+		t.Fatalf("Expected tool error without panic value, got %#v", result)
+	}
+}
+
+func sleepFunc(ctx context.Context, ms int) (string, error) {
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return "done", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestMcpServerToolTimeout(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	if err := server.RegisterTool("sleep", sleepFunc, mcp.McpToolParameter{Name: "ms"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := server.RegisterTool("hang", func(ms int) string { time.Sleep(time.Duration(ms) * time.Millisecond); return "done" }, mcp.McpToolParameter{Name: "ms"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	ctx := NewTestContext()
+
+	expectTimeout := func(ctx context.Context, name string, ms int) {
+		t.Helper()
+		output, err := server.CallTool(ctx, name, ms)
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		if len(output) != 1 || output[0].Type != mcp.McpToolOutputTypeError || output[0].Text != "tool "+name+" timed out" {
+			t.Fatalf("Expected timeout error, got %v", output)
+		}
+	}
+
+	// No limit
+	if output, err := server.CallTool(ctx, "sleep", 10); err != nil || output[0].Text != "done" {
+		t.Fatalf("Unexpected output: %v (error: %v)", output, err)
+	}
+
+	// Server default
+	server.ToolTimeout = 20 * time.Millisecond
+	expectTimeout(ctx, "sleep", 1000)
+
+	// Tool timeout overrides server default
+	if err := server.SetToolTimeout("sleep", 200*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set tool timeout: %v", err)
+	}
+	if output, err := server.CallTool(ctx, "sleep", 50); err != nil || output[0].Text != "done" {
+		t.Fatalf("Unexpected output: %v (error: %v)", output, err)
+	}
+
+	// Tools ignoring the context return when the deadline passes
+	start := time.Now()
+	expectTimeout(ctx, "hang", 1000)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Tool call did not return at deadline: %s", elapsed)
+	}
+
+	// Deadline of the incoming context minus margin
+	server.ToolTimeout = 0
+	server.DeadlineMargin = 250 * time.Millisecond
+	deadlineCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	expectTimeout(deadlineCtx, "hang", 1000)
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("Deadline margin not applied: %s", elapsed)
+	}
+}
+
+func TestMcpServerToolLimits(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	if err := server.RegisterTool("report", func(a int) int { started <- struct{}{}; <-unblock; return a }, mcp.McpToolParameter{Name: "a"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+
+	// callTool calls the tool and returns the limit error, if any
+	callTool := func(sid string, name string) *mcp.McpError {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"`+name+`","arguments":{"a":1,"b":2}}}`))
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		lambdaResp := resp.(events.APIGatewayProxyResponse)
+		var result struct {
+			Error *mcp.McpError `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(lambdaResp.Body), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result.Error != nil {
+			if result.Error.Code != mcp.ErrLimitExceededCode || lambdaResp.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("Expected limit exceeded error, got %s (status %d)", lambdaResp.Body, lambdaResp.StatusCode)
+			}
+			if data, ok := result.Error.Data.(map[string]any); !ok || data["retryAfter"].(float64) <= 0 {
+				t.Fatalf("Expected retryAfter in error data, got %v", result.Error.Data)
+			}
+			// HTTP clients may not read the body of a 429 response, so the delay is sent in a header too
+			if seconds, err := strconv.Atoi(lambdaResp.Headers["Retry-After"]); err != nil || seconds < 1 {
+				t.Fatalf("Expected Retry-After header in whole seconds, got %q", lambdaResp.Headers["Retry-After"])
+			}
+		}
+		return result.Error
+	}
+
+	// expectConcurrencyLimit checks that a second call of report is rejected while the first is running
+	expectConcurrencyLimit := func(sid string, other string) {
+		t.Helper()
+		done := make(chan *mcp.McpError)
+		go func() { done <- callTool(sid, "report") }()
+		<-started
+
+		if err := callTool(sid, other); err == nil {
+			t.Fatalf("Expected %s to be rejected while report is running", other)
+		}
+		unblock <- struct{}{}
+		if err := <-done; err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	sid := initializeSession(t, server, `{}`)
+
+	// Per-tool concurrency
+	if err := server.SetToolMaxConcurrency("report", 1); err != nil {
+		t.Fatalf("Failed to set tool concurrency: %v", err)
+	}
+	expectConcurrencyLimit(sid, "report")
+	if err := server.SetToolMaxConcurrency("report", 0); err != nil {
+		t.Fatalf("Failed to set tool concurrency: %v", err)
+	}
+
+	// Server-wide concurrency
+	server.MaxConcurrentToolCalls = 1
+	expectConcurrencyLimit(sid, "simple_func")
+	server.MaxConcurrentToolCalls = 0
+
+	// Tools abandoned after a timeout hold the slot until they return
+	stuck := make(chan struct{})
+	if err := server.RegisterTool("stuck", func(a int) int { <-stuck; return a }, mcp.McpToolParameter{Name: "a"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := server.SetToolTimeout("stuck", 20*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set tool timeout: %v", err)
+	}
+	if err := server.SetToolMaxConcurrency("stuck", 1); err != nil {
+		t.Fatalf("Failed to set tool concurrency: %v", err)
+	}
+	if err := callTool(sid, "stuck"); err != nil {
+		t.Fatalf("Expected timed out call not to be limited, got %v", err)
+	}
+	if err := callTool(sid, "stuck"); err == nil {
+		t.Fatalf("Expected stuck to be rejected while the abandoned call is running")
+	}
+	close(stuck)
+	released := false
+	for range 100 {
+		if callTool(sid, "stuck") == nil {
+			released = true
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !released {
+		t.Fatalf("Expected slot to be released when the abandoned call returns")
+	}
+
+	// Per-session rate limit
+	server.SessionRateLimit = mcp.McpRateLimit{Rate: 0.1, Burst: 2}
+	for i := range 2 {
+		if err := callTool(sid, "simple_func"); err != nil {
+			t.Fatalf("Unexpected error on call %d: %v", i, err)
+		}
+	}
+	if err := callTool(sid, "context_func"); err == nil {
+		t.Fatalf("Expected session rate limit to be exceeded")
+	}
+	other := initializeSession(t, server, `{}`)
+	if err := callTool(other, "simple_func"); err != nil {
+		t.Fatalf("Expected other session not to be limited, got %v", err)
+	}
+	server.SessionRateLimit = mcp.McpRateLimit{}
+
+	// Per-tool rate limit
+	if err := server.SetToolRateLimit("error_func", mcp.McpRateLimit{Rate: 0.1, Burst: 1}); err != nil {
+		t.Fatalf("Failed to set tool rate limit: %v", err)
+	}
+	if err := callTool(other, "error_func"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := callTool(other, "error_func"); err == nil {
+		t.Fatalf("Expected tool rate limit to be exceeded")
+	}
+	if err := callTool(other, "simple_func"); err != nil {
+		t.Fatalf("Expected other tools not to be limited, got %v", err)
+	}
+
+	// Per-tool rate limits apply to each session separately, global rate limits to all sessions
+	if err := server.SetToolGlobalRateLimit("context_func", mcp.McpRateLimit{Rate: 0.1, Burst: 1}); err != nil {
+		t.Fatalf("Failed to set tool global rate limit: %v", err)
+	}
+	if err := callTool(sid, "error_func"); err != nil {
+		t.Fatalf("Expected tool rate limit of other session not to apply, got %v", err)
+	}
+	if err := callTool(sid, "context_func"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := callTool(other, "context_func"); err == nil {
+		t.Fatalf("Expected tool global rate limit to be exceeded")
+	}
+}
+
+var errQuotaExceeded = mcp.NewMcpError(-32001, "quota exceeded", map[string]any{"quota": "daily"})
+
+func TestMcpServerToolMcpError(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	if err := server.RegisterTool("quota", func(wrap bool) (string, error) {
+		if wrap {
+			return "", fmt.Errorf("cannot generate report: %w", errQuotaExceeded)
+		}
+		return "", errQuotaExceeded
+	}, mcp.McpToolParameter{Name: "wrap"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+
+	// MCP errors returned by tools are protocol errors
+	for _, wrap := range []bool{false, true} {
+		_, err := server.CallTool(NewTestContext(), "quota", wrap)
+		if !errors.Is(err, errQuotaExceeded) {
+			t.Fatalf("Expected errQuotaExceeded from CallTool, got %v", err)
+		}
+		var mcpErr *mcp.McpError
+		if !errors.As(err, &mcpErr) || mcpErr.Code != -32001 {
+			t.Fatalf("Expected *McpError from CallTool, got %v", err)
+		}
+	}
+
+	sid := initializeSession(t, server, `{}`)
+	resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"quota","arguments":{"wrap":true}}}`))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	mcpErr := decodeErrorResponse(t, resp)
+	if mcpErr == nil || mcpErr.Code != -32001 || mcpErr.Message != "quota exceeded" {
+		t.Fatalf("Expected quota exceeded error, got %v", mcpErr)
+	}
+	if data, ok := mcpErr.Data.(map[string]any); !ok || data["quota"] != "daily" {
+		t.Fatalf("Expected error data, got %v", mcpErr.Data)
+	}
+
+	// McpError values are protocol errors as well
+	if err := server.RegisterTool("quota_value", func() error { return fmt.Errorf("wrapped: %w", *errQuotaExceeded) }); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"quota_value","arguments":{}}}`))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	if mcpErr := decodeErrorResponse(t, resp); mcpErr == nil || mcpErr.Code != -32001 {
+		t.Fatalf("Expected quota exceeded error for McpError value, got %v", mcpErr)
+	}
+
+	// Other errors, including errors of the library such as ErrSamplingNotSupported, are tool errors
+	if err := server.RegisterTool("sampling", func() error { return fmt.Errorf("cannot summarize: %w", mcp.ErrSamplingNotSupported) }); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := server.RegisterTool("cart", func(ctx context.Context) error {
+		_, err := mcp.SetSessionValue(ctx, "cart", []string{"apple"}, 7)
+		return err
+	}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	for name, expected := range map[string]string{
+		"error_func": "this is an error",
+		"sampling":   "cannot summarize: " + mcp.ErrSamplingNotSupported.Message,
+		"cart":       mcp.ErrSessionDataVersionConflict.Message,
+	} {
+		resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"`+name+`","arguments":{"a":1,"b":2}}}`))
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		if result := decodeToolResult(t, resp); !result.IsError || result.Content[0].Text != expected {
+			t.Fatalf("Expected tool error from %s, got %#v", name, result)
+		}
+	}
+
+	// Error outputs mark the result as an error and other content is kept
+	if err := server.RegisterTool("failed_login", func() []mcp.McpToolOutput {
+		return []mcp.McpToolOutput{
+			{Type: mcp.McpToolOutputTypeError, Text: "login failed"},
+			mcp.ImageContent(mcp.McpImage{MimeType: mcp.McpImageMimeTypePNG, Data: "screenshot"}),
+			mcp.AudioContent(mcp.McpAudio{MimeType: mcp.McpAudioMimeTypeWAV, Data: "recording"}),
+		}
+	}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"failed_login","arguments":{}}}`))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	result := decodeToolResult(t, resp)
+	if !result.IsError || len(result.Content) != 3 {
+		t.Fatalf("Expected error result with 3 content items, got %#v", result)
+	}
+	if result.Content[0].Type != mcp.McpToolOutputTypeText || result.Content[0].Text != "login failed" ||
+		result.Content[1].Type != mcp.McpToolOutputTypeImage || result.Content[1].Data != "screenshot" ||
+		result.Content[2].Type != mcp.McpToolOutputTypeAudio || result.Content[2].Data != "recording" {
+		t.Fatalf("Unexpected content: %#v", result.Content)
+	}
+
+	// Content returned along with an error is kept, zero values are dropped
+	if err := server.RegisterTool("partial_export", func() (string, []mcp.McpToolOutput, error) {
+		return "", []mcp.McpToolOutput{mcp.TextContent("exported 2 of 3 rows")}, errors.New("row 3 is invalid")
+	}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"partial_export","arguments":{}}}`))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	result = decodeToolResult(t, resp)
+	if !result.IsError || len(result.Content) != 2 ||
+		result.Content[0].Text != "exported 2 of 3 rows" || result.Content[1].Text != "row 3 is invalid" {
+		t.Fatalf("Expected error result with partial content and error, got %#v", result)
+	}
+
+	// MCP errors returned by methods keep their code
+	if err := server.RegisterMethod("test/error", func(ctx context.Context, req *mcp.McpRequest) (*mcp.McpResponse, error) {
+		return nil, fmt.Errorf("wrapped: %w", errQuotaExceeded)
+	}); err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+	resp, err = server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":5,"method":"test/error"}`))
+	if err != nil {
+		t.Fatalf("Failed to process request: %v", err)
+	}
+	if mcpErr := decodeErrorResponse(t, resp); mcpErr == nil || mcpErr.Code != -32001 {
+		t.Fatalf("Expected method error code, got %v", mcpErr)
+	}
+}
+
+func screenshotFunc(ctx context.Context, count int) ([]mcp.McpToolOutput, error) {
+	output := []mcp.McpToolOutput{mcp.TextContent(fmt.Sprintf("%d screenshots of the login page", count))}
+	for i := range count {
+		output = append(output, mcp.ImageContent(mcp.McpImage{MimeType: mcp.McpImageMimeTypePNG, Data: fmt.Sprintf("image%d", i)}))
+	}
+	output = append(output, mcp.ResourceContent(mcp.McpResourceContents{URI: "file:///logs/login.log", MimeType: "text/plain", Text: "login ok"}))
+	return output, nil
+}
+
+func TestMcpServerToolContent(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+
+	if err := server.RegisterTool("screenshot", screenshotFunc, mcp.McpToolParameter{Name: "count"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := server.RegisterTool("greeting", func(name string) mcp.McpToolOutput { return mcp.TextContent("Hello " + name) }, mcp.McpToolParameter{Name: "name"}); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := server.RegisterTool("names", func() []string { return nil }); err == nil {
+		t.Fatalf("Expected error registering tool returning []string")
+	}
+
+	sid := initializeSession(t, server, `{}`)
+	resp, err := server.ProcessRequest(context.TODO(), newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"screenshot","arguments":{"count":2}}}`))
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	result := decodeToolResult(t, resp)
+	if result.IsError || len(result.Content) != 4 {
+		t.Fatalf("Expected 4 content items, got %#v", result)
+	}
+	if result.Content[0].Text != "2 screenshots of the login page" ||
+		result.Content[1].Type != mcp.McpToolOutputTypeImage || result.Content[2].Data != "image1" ||
+		result.Content[3].Type != mcp.McpToolOutputTypeResource || result.Content[3].Resource.Text != "login ok" {
+		t.Fatalf("Unexpected content: %#v", result.Content)
+	}
+
+	output, err := server.CallTool(NewTestContext(), "greeting", "MCP")
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	if len(output) != 1 || output[0].Text != "Hello MCP" {
+		t.Fatalf("Unexpected output: %v", output)
+	}
+}
+
+func TestMcpServerAudio(t *testing.T) {
+	server, err := NewTestMcpServer()
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = memory.NewSessionManager()
+	ctx := context.TODO()
+
+	tool, err := server.GetTool("audio_func")
+	if err != nil {
+		t.Fatalf("Failed to get tool: %v", err)
+	}
+	if tool.Parameters[1].Type != mcp.McpToolDataTypeAudio || tool.Output[0].Type != mcp.McpToolDataTypeAudio {
+		t.Fatalf("Expected audio parameter and output, got %v", tool)
+	}
+
+	sid := initializeSession(t, server, `{}`)
+	callTool := func(mimeType string) any {
+		resp, err := server.ProcessRequest(ctx, newLambdaRequest(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"audio_func","arguments":{"audio":{"data":"UklGRg==","mimeType":"`+mimeType+`"}}}}`))
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		return resp
+	}
+
+	result := decodeToolResult(t, callTool("audio/mpeg"))
+	if len(result.Content) != 1 || result.Content[0].Type != mcp.McpToolOutputTypeAudio || result.Content[0].MimeType != "audio/mpeg" || result.Content[0].Data != "UklGRg==" {
+		t.Fatalf("Unexpected audio result: %#v", result)
+	}
+
+	if mcpErr := decodeErrorResponse(t, callTool("audio/flac")); mcpErr == nil || mcpErr.Message != "audio MIME type not supported" {
+		t.Fatalf("Expected unsupported MIME type error, got %v", mcpErr)
+	}
+
+	audio := mcp.McpAudio{MimeType: mcp.McpAudioMimeTypeWAV, Data: "UklGRg=="}
+	if data, err := audio.GetAudioBinary(); err != nil || string(data) != "RIFF" {
+		t.Fatalf("Unexpected audio binary: %q (error: %v)", data, err)
+	}
+}
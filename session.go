@@ -2,7 +2,9 @@ package mcp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
 )
 
 type McpSessionManager interface {
@@ -14,6 +16,39 @@ type McpSessionManager interface {
 
 	// SetSessionInitialized sets the initialized state of a session.
 	SetSessionInitialized(session McpSession, init bool) (McpSession, error)
+
+	// DeleteSession terminates a session. It returns ErrSessionNotFound if the session does not exist,
+	// or ErrSessionTerminationNotSupported if sessions cannot be terminated by the client.
+	DeleteSession(sessionID string) error
+}
+
+// McpSessionLookupManager is implemented by session managers backed by a store which can fail, e.g. a database.
+// The server uses LookupSession instead of GetSession, so storage errors are not reported to the client as unknown sessions.
+type McpSessionLookupManager interface {
+	McpSessionManager
+
+	// LookupSession retrieves a session by its ID. It returns ErrSessionNotFound if the session does not exist or has expired,
+	// and another error if the session store cannot be read.
+	LookupSession(sessionID string) (McpSession, error)
+}
+
+// McpSessionDataManager is implemented by session managers which provide a per-session data store.
+// Session values and client information are only available with session managers implementing it.
+type McpSessionDataManager interface {
+	McpSessionManager
+
+	// GetSessionData retrieves a value from the session data store.
+	// It returns ErrSessionDataNotFound if the key does not exist.
+	GetSessionData(session McpSession, key string) (McpSessionData, error)
+
+	// SetSessionData stores a value in the session data store if data.Version matches the current version
+	// of the key (0 if the key must not exist yet). It returns the updated session and the stored value with its new version.
+	// It returns ErrSessionDataVersionConflict if the version does not match.
+	SetSessionData(session McpSession, data McpSessionData) (McpSession, McpSessionData, error)
+
+	// DeleteSessionData removes a value from the session data store if version matches the current version of the key.
+	// It returns ErrSessionDataVersionConflict if the version does not match.
+	DeleteSessionData(session McpSession, key string, version int64) (McpSession, error)
 }
 
 // McpSession represents a session in the MCP protocol.
@@ -22,15 +57,62 @@ type McpSessionManager interface {
 type McpSession struct {
 	SessionID   string // Session ID
 	Initialized bool   // Session initialized
+
+	// StableID identifies the session across reissued session IDs (e.g. signed tokens which change on every update).
+	// Empty if the session ID never changes.
+	StableID string
+}
+
+// StableSessionID returns the identifier of the session which does not change during the lifetime of the session.
+// Use it to key per-session state kept outside the session manager.
+func (s McpSession) StableSessionID() string {
+	if s.StableID != "" {
+		return s.StableID
+	}
+	return s.SessionID
+}
+
+// McpSessionData is a value in the session data store.
+type McpSessionData struct {
+	Key     string          // Key of the value
+	Value   json.RawMessage // JSON encoded value
+	Version int64           // Version of the value. Incremented on every change
+}
+
+// McpSessionClient describes the client of a session.
+// It is recorded in the session data store during initialization.
+type McpSessionClient struct {
+	ProtocolVersion McpProtocolVersion    `json:"protocolVersion"` // Negotiated protocol version
+	ClientInfo      McpClientInfo         `json:"clientInfo"`      // Client info
+	Capabilities    McpClientCapabilities `json:"capabilities"`    // Client capabilities
 }
 
+// McpSessionDataKeyClient is the session data key holding McpSessionClient.
+const McpSessionDataKeyClient = "mcp/client"
+
 type MpcContextKey string
 
 const McpRequestIDKey MpcContextKey = "mcp_request_id"
-const McpSessionContextKey MpcContextKey = "mcp_session"
+const McpSessionContextKey MpcContextKey = "mcp_session" // Holds the McpSession of the request as it was when the request started
+const McpSessionManagerContextKey MpcContextKey = "mcp_session_manager"
+const McpServerContextKey MpcContextKey = "mcp_server"
+
+type sessionRefContextKey struct{}
+
+// sessionRef holds the session of the current request.
+// The session is replaced when the session manager returns an updated session.
+type sessionRef struct {
+	mu      sync.Mutex
+	session McpSession
+}
 
-// GetSessionFromContext retrieves the session from the context.
+// GetSessionFromContext retrieves the latest session of the current request from the context.
 func GetSessionFromContext(ctx context.Context) (McpSession, error) {
+	if ref, ok := ctx.Value(sessionRefContextKey{}).(*sessionRef); ok {
+		ref.mu.Lock()
+		defer ref.mu.Unlock()
+		return ref.session, nil
+	}
 	sess, ok := ctx.Value(McpSessionContextKey).(McpSession)
 	if !ok {
 		return McpSession{}, fmt.Errorf("session not found")
@@ -39,7 +121,133 @@ func GetSessionFromContext(ctx context.Context) (McpSession, error) {
 }
 
 func SetSessionInContext(ctx context.Context, session McpSession) context.Context {
-	return context.WithValue(ctx, McpSessionContextKey, session)
+	ctx = context.WithValue(ctx, McpSessionContextKey, session)
+	return context.WithValue(ctx, sessionRefContextKey{}, &sessionRef{session: session})
+}
+
+// updateSessionInContext replaces the session of the current request with the session returned by the session manager.
+func updateSessionInContext(ctx context.Context, session McpSession) {
+	if ref, ok := ctx.Value(sessionRefContextKey{}).(*sessionRef); ok {
+		ref.mu.Lock()
+		ref.session = session
+		ref.mu.Unlock()
+	}
+}
+
+// GetSessionManagerFromContext retrieves the session manager from the context.
+func GetSessionManagerFromContext(ctx context.Context) (McpSessionManager, error) {
+	manager, ok := ctx.Value(McpSessionManagerContextKey).(McpSessionManager)
+	if !ok {
+		return nil, fmt.Errorf("session manager not found")
+	}
+	return manager, nil
+}
+
+func SetSessionManagerInContext(ctx context.Context, manager McpSessionManager) context.Context {
+	return context.WithValue(ctx, McpSessionManagerContextKey, manager)
+}
+
+// getSessionDataManager retrieves the session manager from the context if it provides a session data store.
+func getSessionDataManager(ctx context.Context) (McpSessionDataManager, error) {
+	manager, err := GetSessionManagerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dm, ok := manager.(McpSessionDataManager)
+	if !ok {
+		return nil, ErrSessionDataNotSupported
+	}
+	return dm, nil
+}
+
+// GetSessionValue decodes the value stored under key in the data store of the current session into v
+// and returns the version of the value.
+func GetSessionValue(ctx context.Context, key string, v any) (int64, error) {
+	manager, err := getSessionDataManager(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := manager.GetSessionData(sess, key)
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(data.Value, v); err != nil {
+		return 0, fmt.Errorf("cannot decode session value %s: %w", key, err)
+	}
+	return data.Version, nil
+}
+
+// SetSessionValue stores v under key in the data store of the current session and returns the new version.
+// version must be the current version of the value, or 0 if the key does not exist yet.
+func SetSessionValue(ctx context.Context, key string, v any, version int64) (int64, error) {
+	manager, err := getSessionDataManager(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return 0, fmt.Errorf("cannot encode session value %s: %w", key, err)
+	}
+
+	sess, data, err := manager.SetSessionData(sess, McpSessionData{Key: key, Value: value, Version: version})
+	if err != nil {
+		return 0, err
+	}
+	updateSessionInContext(ctx, sess)
+	return data.Version, nil
+}
+
+// DeleteSessionValue removes the value stored under key from the data store of the current session.
+// version must be the current version of the value.
+func DeleteSessionValue(ctx context.Context, key string, version int64) error {
+	manager, err := getSessionDataManager(ctx)
+	if err != nil {
+		return err
+	}
+	sess, err := GetSessionFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	sess, err = manager.DeleteSessionData(sess, key, version)
+	if err != nil {
+		return err
+	}
+	updateSessionInContext(ctx, sess)
+	return nil
+}
+
+// GetSessionClientFromContext retrieves information about the client of the current session
+// recorded during initialization.
+func GetSessionClientFromContext(ctx context.Context) (McpSessionClient, error) {
+	var client McpSessionClient
+	if _, err := GetSessionValue(ctx, McpSessionDataKeyClient, &client); err != nil {
+		return McpSessionClient{}, err
+	}
+	return client, nil
+}
+
+// GetServerFromContext retrieves the server processing the current request from the context.
+func GetServerFromContext(ctx context.Context) (*McpServer, error) {
+	s, ok := ctx.Value(McpServerContextKey).(*McpServer)
+	if !ok {
+		return nil, fmt.Errorf("server not found")
+	}
+	return s, nil
+}
+
+func SetServerInContext(ctx context.Context, s *McpServer) context.Context {
+	return context.WithValue(ctx, McpServerContextKey, s)
 }
 
 // GetRequestIDFromContext retrieves the request ID from the context.
@@ -0,0 +1,21 @@
+module github.com/puttsk/go-mcp/session/dynamodb
+
+go 1.22
+
+require (
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.40.0
+	github.com/google/uuid v1.6.0
+	github.com/puttsk/go-mcp v0.0.0
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.36.1 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.3.32 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.32 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.12.2 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/endpoint-discovery v1.10.13 // indirect
+	github.com/aws/smithy-go v1.22.2 // indirect
+)
+
+// Build against the parent module in this repository. Require its released version when tagging this module
+replace github.com/puttsk/go-mcp => ../..
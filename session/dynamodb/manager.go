@@ -0,0 +1,492 @@
+// DynamoDB-backed session manager. Sessions are stored in a DynamoDB table so they can be
+// shared across AWS Lambda instances.
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/puttsk/go-mcp"
+)
+
+// Client is the subset of the DynamoDB API used by the session manager.
+// It is satisfied by *dynamodb.Client from the AWS SDK and can be replaced with a fake in tests.
+type Client interface {
+	GetItem(ctx context.Context, params *ddb.GetItemInput, optFns ...func(*ddb.Options)) (*ddb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *ddb.PutItemInput, optFns ...func(*ddb.Options)) (*ddb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *ddb.UpdateItemInput, optFns ...func(*ddb.Options)) (*ddb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *ddb.DeleteItemInput, optFns ...func(*ddb.Options)) (*ddb.DeleteItemOutput, error)
+}
+
+// Default attribute names used when none are configured.
+const (
+	DefaultPartitionKey         = "SessionID"
+	DefaultInitializedAttribute = "Initialized"
+	DefaultTTLAttribute         = "ExpiresAt"
+	DefaultDataAttribute        = "Data"
+	DefaultTTL                  = 24 * time.Hour
+	DefaultOperationTimeout     = 5 * time.Second
+)
+
+type SessionManager struct {
+	Debug bool
+
+	Client    Client // DynamoDB client
+	TableName string // Name of the session table
+
+	PartitionKey string // Name of the partition key attribute holding the session ID
+	SortKey      string // Name of the sort key attribute. Leave empty if the table has no sort key
+	SortKeyValue string // Value stored in the sort key attribute for session items
+
+	InitializedAttribute string        // Name of the attribute holding the initialized state
+	DataAttribute        string        // Name of the map attribute holding the session data store
+	TTLAttribute         string        // Name of the TTL attribute (epoch seconds). Leave empty to disable expiry
+	TTL                  time.Duration // Lifetime of a session
+
+	OperationTimeout time.Duration // Time limit of each DynamoDB request. Zero uses DefaultOperationTimeout
+}
+
+// NewSessionManager creates a session manager storing sessions in the given table
+// using the default key schema and TTL.
+func NewSessionManager(client Client, tableName string) *SessionManager {
+	return &SessionManager{
+		Client:               client,
+		TableName:            tableName,
+		PartitionKey:         DefaultPartitionKey,
+		InitializedAttribute: DefaultInitializedAttribute,
+		DataAttribute:        DefaultDataAttribute,
+		TTLAttribute:         DefaultTTLAttribute,
+		TTL:                  DefaultTTL,
+		OperationTimeout:     DefaultOperationTimeout,
+	}
+}
+
+// operationContext returns the context of a DynamoDB request limited to OperationTimeout.
+func (s *SessionManager) operationContext() (context.Context, context.CancelFunc) {
+	timeout := s.OperationTimeout
+	if timeout <= 0 {
+		timeout = DefaultOperationTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// key returns the primary key of the item holding the given session.
+func (s *SessionManager) key(sessionID string) map[string]types.AttributeValue {
+	key := map[string]types.AttributeValue{
+		s.PartitionKey: &types.AttributeValueMemberS{Value: sessionID},
+	}
+	if s.SortKey != "" {
+		key[s.SortKey] = &types.AttributeValueMemberS{Value: s.SortKeyValue}
+	}
+	return key
+}
+
+// liveCondition returns a condition expression matching an existing session which has not expired
+// and adds the attributes it uses to names and values.
+func (s *SessionManager) liveCondition(names map[string]string, values map[string]types.AttributeValue) string {
+	cond := "attribute_exists(#pk)"
+	names["#pk"] = s.PartitionKey
+	if s.TTLAttribute != "" {
+		cond += " AND #ttl > :now"
+		names["#ttl"] = s.TTLAttribute
+		values[":now"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)}
+	}
+	return cond
+}
+
+// live reports whether the item exists and has not expired.
+// DynamoDB removes expired items lazily, so expiry must be checked on read.
+func (s *SessionManager) live(item map[string]types.AttributeValue) bool {
+	if item == nil {
+		return false
+	}
+	if s.TTLAttribute != "" {
+		if v, ok := item[s.TTLAttribute].(*types.AttributeValueMemberN); ok {
+			expiresAt, err := strconv.ParseInt(v.Value, 10, 64)
+			if err == nil && expiresAt <= time.Now().Unix() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// getItem returns the item of the session. It returns ErrSessionNotFound if the session does not exist or has expired.
+func (s *SessionManager) getItem(sessionID string) (map[string]types.AttributeValue, error) {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	out, err := s.Client.GetItem(ctx, &ddb.GetItemInput{
+		TableName:      &s.TableName,
+		Key:            s.key(sessionID),
+		ConsistentRead: boolPtr(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get session: %w", err)
+	}
+	if !s.live(out.Item) {
+		return nil, mcp.ErrSessionNotFound
+	}
+	return out.Item, nil
+}
+
+// GetSession retrieves a session by its ID. Storage errors are logged and reported as a missing session,
+// use LookupSession to tell them apart.
+func (s *SessionManager) GetSession(sessionID string) (mcp.McpSession, bool) {
+	sess, err := s.LookupSession(sessionID)
+	if err != nil {
+		if !errors.Is(err, mcp.ErrSessionNotFound) {
+			log.Printf("Cannot get session %s: %v", sessionID, err)
+		}
+		return mcp.McpSession{}, false
+	}
+	return sess, true
+}
+
+func (s *SessionManager) LookupSession(sessionID string) (mcp.McpSession, error) {
+	item, err := s.getItem(sessionID)
+	if err != nil {
+		return mcp.McpSession{}, err
+	}
+
+	sess := mcp.McpSession{
+		SessionID: sessionID,
+	}
+	if v, ok := item[s.InitializedAttribute].(*types.AttributeValueMemberBOOL); ok {
+		sess.Initialized = v.Value
+	}
+
+	return sess, nil
+}
+
+func (s *SessionManager) CreateSession() (mcp.McpSession, error) {
+	// Generate a new UUID for the session ID
+	sessID, err := uuid.NewRandom()
+	if err != nil {
+		return mcp.McpSession{}, err
+	}
+
+	sess := mcp.McpSession{
+		SessionID: sessID.String(),
+	}
+
+	item := s.key(sess.SessionID)
+	item[s.InitializedAttribute] = &types.AttributeValueMemberBOOL{Value: false}
+	item[s.DataAttribute] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+	if s.TTLAttribute != "" {
+		item[s.TTLAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(s.TTL).Unix(), 10)}
+	}
+
+	// Never overwrite an existing session
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	_, err = s.Client.PutItem(ctx, &ddb.PutItemInput{
+		TableName:                &s.TableName,
+		Item:                     item,
+		ConditionExpression:      strPtr("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{"#pk": s.PartitionKey},
+	})
+	if err != nil {
+		return mcp.McpSession{}, fmt.Errorf("cannot store session: %w", err)
+	}
+
+	if s.Debug {
+		log.Printf("Session created: %#v", sess)
+	}
+	return sess, nil
+}
+
+func (s *SessionManager) SetSessionInitialized(session mcp.McpSession, init bool) (mcp.McpSession, error) {
+	// Only update sessions which exist and have not expired
+	names := map[string]string{
+		"#init": s.InitializedAttribute,
+	}
+	values := map[string]types.AttributeValue{
+		":init": &types.AttributeValueMemberBOOL{Value: init},
+	}
+	cond := s.liveCondition(names, values)
+
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	_, err := s.Client.UpdateItem(ctx, &ddb.UpdateItemInput{
+		TableName:                 &s.TableName,
+		Key:                       s.key(session.SessionID),
+		UpdateExpression:          strPtr("SET #init = :init"),
+		ConditionExpression:       &cond,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			// Session not found
+			return session, mcp.ErrSessionNotFound
+		}
+		return session, fmt.Errorf("cannot update session: %w", err)
+	}
+
+	newSession := session
+	newSession.Initialized = init
+
+	if s.Debug {
+		log.Printf("Update Session: %#v", newSession)
+	}
+	return newSession, nil
+}
+
+func (s *SessionManager) DeleteSession(sessionID string) error {
+	// Only delete sessions which exist and have not expired
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	cond := s.liveCondition(names, values)
+
+	input := &ddb.DeleteItemInput{
+		TableName:                &s.TableName,
+		Key:                      s.key(sessionID),
+		ConditionExpression:      &cond,
+		ExpressionAttributeNames: names,
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	_, err := s.Client.DeleteItem(ctx, input)
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			// Session not found
+			return mcp.ErrSessionNotFound
+		}
+		return fmt.Errorf("cannot delete session: %w", err)
+	}
+
+	if s.Debug {
+		log.Printf("Session deleted: %s", sessionID)
+	}
+	return nil
+}
+
+func (s *SessionManager) GetSessionData(session mcp.McpSession, key string) (mcp.McpSessionData, error) {
+	item, err := s.getItem(session.SessionID)
+	if err != nil {
+		return mcp.McpSessionData{}, err
+	}
+
+	data, ok := s.dataEntry(item, key)
+	if !ok {
+		return mcp.McpSessionData{}, mcp.ErrSessionDataNotFound
+	}
+	return data, nil
+}
+
+func (s *SessionManager) SetSessionData(session mcp.McpSession, data mcp.McpSessionData) (mcp.McpSession, mcp.McpSessionData, error) {
+	stored, deleted := data.Version, false
+	for attempt := 0; ; attempt++ {
+		old, err := s.putDataEntry(session.SessionID, data, stored, deleted)
+		if err == nil {
+			break
+		}
+		if old == nil || attempt == 2 {
+			return session, mcp.McpSessionData{}, err
+		}
+
+		switch entry, ok := s.rawDataEntry(old, data.Key); {
+		case !s.live(old):
+			// Session not found
+			return session, mcp.McpSessionData{}, mcp.ErrSessionNotFound
+		case old[s.DataAttribute] == nil:
+			// Session created before the data store was added
+			if err := s.initDataStore(session.SessionID); err != nil {
+				return session, mcp.McpSessionData{}, err
+			}
+		case data.Version == 0 && ok && entry.Value == nil && !deleted:
+			// Re-create a deleted key
+			stored, deleted = entry.Version, true
+		default:
+			return session, mcp.McpSessionData{}, mcp.ErrSessionDataVersionConflict
+		}
+	}
+	data.Version = stored + 1
+
+	if s.Debug {
+		log.Printf("Update session data: %s %s (version %d)", session.SessionID, data.Key, data.Version)
+	}
+	return session, data, nil
+}
+
+// putDataEntry stores the value if the stored entry of the key has the given version, or does not exist if version is 0.
+// Deleted keys are kept as entries holding only the version so a re-created key never reuses a version,
+// and deleted selects whether the stored entry is such a deleted key. The old item is returned if a condition fails.
+func (s *SessionManager) putDataEntry(sessionID string, data mcp.McpSessionData, version int64, deleted bool) (map[string]types.AttributeValue, error) {
+	names := map[string]string{
+		"#data": s.DataAttribute,
+		"#key":  data.Key,
+	}
+	values := map[string]types.AttributeValue{
+		":entry": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"Value":   &types.AttributeValueMemberS{Value: string(data.Value)},
+			"Version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version+1, 10)},
+		}},
+	}
+	cond := s.liveCondition(names, values) + " AND attribute_exists(#data)"
+	if version == 0 {
+		// Key must not exist
+		cond += " AND attribute_not_exists(#data.#key)"
+	} else {
+		// Version must match
+		cond += " AND #data.#key.#ver = :ver"
+		names["#ver"] = "Version"
+		names["#val"] = "Value"
+		values[":ver"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)}
+		if deleted {
+			cond += " AND attribute_not_exists(#data.#key.#val)"
+		} else {
+			cond += " AND attribute_exists(#data.#key.#val)"
+		}
+	}
+
+	return s.updateDataEntry(sessionID, cond, names, values, "cannot set session data")
+}
+
+// updateDataEntry replaces the entry of the session data store with :entry if the condition matches.
+// The old item is returned if the condition fails.
+func (s *SessionManager) updateDataEntry(sessionID string, cond string, names map[string]string, values map[string]types.AttributeValue, msg string) (map[string]types.AttributeValue, error) {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	_, err := s.Client.UpdateItem(ctx, &ddb.UpdateItemInput{
+		TableName:                           &s.TableName,
+		Key:                                 s.key(sessionID),
+		UpdateExpression:                    strPtr("SET #data.#key = :entry"),
+		ConditionExpression:                 &cond,
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			if condErr.Item == nil {
+				// Session not found
+				return nil, mcp.ErrSessionNotFound
+			}
+			return condErr.Item, mcp.ErrSessionDataVersionConflict
+		}
+		return nil, fmt.Errorf("%s: %w", msg, err)
+	}
+	return nil, nil
+}
+
+// initDataStore adds an empty data store to a session item which has none.
+func (s *SessionManager) initDataStore(sessionID string) error {
+	names := map[string]string{
+		"#data": s.DataAttribute,
+	}
+	values := map[string]types.AttributeValue{
+		":empty": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}},
+	}
+	cond := s.liveCondition(names, values) + " AND attribute_not_exists(#data)"
+
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	_, err := s.Client.UpdateItem(ctx, &ddb.UpdateItemInput{
+		TableName:                 &s.TableName,
+		Key:                       s.key(sessionID),
+		UpdateExpression:          strPtr("SET #data = :empty"),
+		ConditionExpression:       &cond,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			// Session removed or data store added concurrently
+			return nil
+		}
+		return fmt.Errorf("cannot initialize session data: %w", err)
+	}
+	return nil
+}
+
+func (s *SessionManager) DeleteSessionData(session mcp.McpSession, key string, version int64) (mcp.McpSession, error) {
+	names := map[string]string{
+		"#data": s.DataAttribute,
+		"#key":  key,
+		"#ver":  "Version",
+		"#val":  "Value",
+	}
+	values := map[string]types.AttributeValue{
+		":ver": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+		":entry": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"Version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+		}},
+	}
+	cond := s.liveCondition(names, values) + " AND #data.#key.#ver = :ver AND attribute_exists(#data.#key.#val)"
+
+	old, err := s.updateDataEntry(session.SessionID, cond, names, values, "cannot delete session data")
+	if err != nil {
+		if old == nil {
+			return session, err
+		}
+		if !s.live(old) {
+			// Session not found
+			return session, mcp.ErrSessionNotFound
+		}
+		if _, ok := s.dataEntry(old, key); !ok {
+			return session, mcp.ErrSessionDataNotFound
+		}
+		return session, mcp.ErrSessionDataVersionConflict
+	}
+
+	if s.Debug {
+		log.Printf("Delete session data: %s %s", session.SessionID, key)
+	}
+	return session, nil
+}
+
+// rawDataEntry reads an entry of the session data store from the session item, including deleted keys
+// which have a nil value.
+func (s *SessionManager) rawDataEntry(item map[string]types.AttributeValue, key string) (mcp.McpSessionData, bool) {
+	store, ok := item[s.DataAttribute].(*types.AttributeValueMemberM)
+	if !ok {
+		return mcp.McpSessionData{}, false
+	}
+	entry, ok := store.Value[key].(*types.AttributeValueMemberM)
+	if !ok {
+		return mcp.McpSessionData{}, false
+	}
+
+	data := mcp.McpSessionData{
+		Key: key,
+	}
+	if v, ok := entry.Value["Value"].(*types.AttributeValueMemberS); ok {
+		data.Value = []byte(v.Value)
+	}
+	if v, ok := entry.Value["Version"].(*types.AttributeValueMemberN); ok {
+		data.Version, _ = strconv.ParseInt(v.Value, 10, 64)
+	}
+	return data, true
+}
+
+// dataEntry reads a value of the session data store from the session item.
+func (s *SessionManager) dataEntry(item map[string]types.AttributeValue, key string) (mcp.McpSessionData, bool) {
+	data, ok := s.rawDataEntry(item, key)
+	if !ok || data.Value == nil {
+		return mcp.McpSessionData{}, false
+	}
+	return data, true
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,250 @@
+package dynamodb_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/puttsk/go-mcp"
+	"github.com/puttsk/go-mcp/session/dynamodb"
+	"github.com/puttsk/go-mcp/session/sessiontest"
+)
+
+// fakeClient is an in-memory stand-in for DynamoDB supporting the conditions used by the session manager.
+type fakeClient struct {
+	mu    sync.Mutex
+	pk    string
+	ttl   string
+	items map[string]map[string]types.AttributeValue
+
+	getErr   error // Error returned by GetItem, e.g. throttling
+	getBlock bool  // GetItem blocks until the context is done, e.g. an unreachable endpoint
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		pk:    dynamodb.DefaultPartitionKey,
+		ttl:   dynamodb.DefaultTTLAttribute,
+		items: map[string]map[string]types.AttributeValue{},
+	}
+}
+
+func (c *fakeClient) id(key map[string]types.AttributeValue) string {
+	return key[c.pk].(*types.AttributeValueMemberS).Value
+}
+
+// expired reports whether the item fails the ":now" expiry condition.
+func (c *fakeClient) expired(item map[string]types.AttributeValue, values map[string]types.AttributeValue) bool {
+	now, ok := values[":now"].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	exp, _ := strconv.ParseInt(item[c.ttl].(*types.AttributeValueMemberN).Value, 10, 64)
+	n, _ := strconv.ParseInt(now.Value, 10, 64)
+	return exp <= n
+}
+
+func (c *fakeClient) GetItem(ctx context.Context, in *ddb.GetItemInput, optFns ...func(*ddb.Options)) (*ddb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.getBlock {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if c.getErr != nil {
+		return nil, c.getErr
+	}
+	return &ddb.GetItemOutput{Item: c.items[c.id(in.Key)]}, nil
+}
+
+func (c *fakeClient) PutItem(ctx context.Context, in *ddb.PutItemInput, optFns ...func(*ddb.Options)) (*ddb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.id(in.Item)
+	if _, ok := c.items[id]; ok && in.ConditionExpression != nil {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	c.items[id] = in.Item
+	return &ddb.PutItemOutput{}, nil
+}
+
+func (c *fakeClient) UpdateItem(ctx context.Context, in *ddb.UpdateItemInput, optFns ...func(*ddb.Options)) (*ddb.UpdateItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[c.id(in.Key)]
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	if c.expired(item, in.ExpressionAttributeValues) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	switch *in.UpdateExpression {
+	case "SET #init = :init":
+		item[in.ExpressionAttributeNames["#init"]] = in.ExpressionAttributeValues[":init"]
+	case "SET #data = :empty":
+		name := in.ExpressionAttributeNames["#data"]
+		if _, ok := item[name]; ok {
+			return nil, &types.ConditionalCheckFailedException{Item: item}
+		}
+		item[name] = in.ExpressionAttributeValues[":empty"]
+	case "SET #data.#key = :entry":
+		cond := *in.ConditionExpression
+		store, ok := item[in.ExpressionAttributeNames["#data"]].(*types.AttributeValueMemberM)
+		if !ok {
+			return nil, &types.ConditionalCheckFailedException{Item: item}
+		}
+		key := in.ExpressionAttributeNames["#key"]
+		entry, exists := store.Value[key].(*types.AttributeValueMemberM)
+		if exists && strings.Contains(cond, "attribute_not_exists(#data.#key)") {
+			return nil, &types.ConditionalCheckFailedException{Item: item}
+		}
+		if ver, ok := in.ExpressionAttributeValues[":ver"]; ok {
+			if !exists || entry.Value["Version"].(*types.AttributeValueMemberN).Value != ver.(*types.AttributeValueMemberN).Value {
+				return nil, &types.ConditionalCheckFailedException{Item: item}
+			}
+			_, hasValue := entry.Value["Value"]
+			if hasValue != strings.Contains(cond, "attribute_exists(#data.#key.#val)") {
+				return nil, &types.ConditionalCheckFailedException{Item: item}
+			}
+		}
+		store.Value[key] = in.ExpressionAttributeValues[":entry"]
+	}
+	return &ddb.UpdateItemOutput{}, nil
+}
+
+func (c *fakeClient) DeleteItem(ctx context.Context, in *ddb.DeleteItemInput, optFns ...func(*ddb.Options)) (*ddb.DeleteItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.id(in.Key)
+	item, ok := c.items[id]
+	if !ok || c.expired(item, in.ExpressionAttributeValues) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	delete(c.items, id)
+	return &ddb.DeleteItemOutput{}, nil
+}
+
+func TestSessionManager(t *testing.T) {
+	client := newFakeClient()
+	manager := dynamodb.NewSessionManager(client, "sessions")
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	got, ok := manager.GetSession(sess.SessionID)
+	if !ok {
+		t.Fatalf("Session %s not found", sess.SessionID)
+	}
+	if got.Initialized {
+		t.Fatalf("New session must not be initialized")
+	}
+
+	if _, err := manager.SetSessionInitialized(sess, true); err != nil {
+		t.Fatalf("Failed to initialize session: %v", err)
+	}
+
+	// A second manager simulates another Lambda instance sharing the table
+	other := dynamodb.NewSessionManager(client, "sessions")
+	got, ok = other.GetSession(sess.SessionID)
+	if !ok || !got.Initialized {
+		t.Fatalf("Expected initialized session from other instance, got %#v (found: %t)", got, ok)
+	}
+
+	if _, ok := manager.GetSession("unknown"); ok {
+		t.Fatalf("Unknown session found")
+	}
+	if _, err := manager.SetSessionInitialized(mcp.McpSession{SessionID: "unknown"}, true); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSessionManagerExpiry(t *testing.T) {
+	client := newFakeClient()
+	manager := dynamodb.NewSessionManager(client, "sessions")
+	manager.TTL = -time.Second
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if _, ok := manager.GetSession(sess.SessionID); ok {
+		t.Fatalf("Expired session found")
+	}
+	if _, err := manager.SetSessionInitialized(sess, true); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if err := manager.DeleteSession(sess.SessionID); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSessionManagerLookupError(t *testing.T) {
+	client := newFakeClient()
+	manager := dynamodb.NewSessionManager(client, "sessions")
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := manager.LookupSession("unknown"); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	// Throttling is not a missing session
+	client.getErr = errors.New("throttled")
+	if _, err := manager.LookupSession(sess.SessionID); err == nil || errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected storage error, got %v", err)
+	}
+	if _, err := manager.GetSessionData(sess, "cart"); err == nil || errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected storage error, got %v", err)
+	}
+}
+
+func TestSessionManagerOperationTimeout(t *testing.T) {
+	client := newFakeClient()
+	manager := dynamodb.NewSessionManager(client, "sessions")
+	manager.OperationTimeout = 10 * time.Millisecond
+
+	client.getBlock = true
+	if _, err := manager.LookupSession("blocked"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSessionManagerData(t *testing.T) {
+	sessiontest.TestSessionData(t, dynamodb.NewSessionManager(newFakeClient(), "sessions"))
+}
+
+func TestSessionManagerDataLegacyItem(t *testing.T) {
+	client := newFakeClient()
+	manager := dynamodb.NewSessionManager(client, "sessions")
+
+	// Session stored before the data store was added has no data map
+	client.items["legacy"] = map[string]types.AttributeValue{
+		dynamodb.DefaultPartitionKey:         &types.AttributeValueMemberS{Value: "legacy"},
+		dynamodb.DefaultInitializedAttribute: &types.AttributeValueMemberBOOL{Value: true},
+		dynamodb.DefaultTTLAttribute:         &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)},
+	}
+	sess := mcp.McpSession{SessionID: "legacy", Initialized: true}
+
+	if _, err := manager.GetSessionData(sess, "cart"); !errors.Is(err, mcp.ErrSessionDataNotFound) {
+		t.Fatalf("Expected ErrSessionDataNotFound, got %v", err)
+	}
+	_, data, err := manager.SetSessionData(sess, mcp.McpSessionData{Key: "cart", Value: []byte(`["apple"]`)})
+	if err != nil {
+		t.Fatalf("Failed to set session data: %v", err)
+	}
+	if data.Version != 1 {
+		t.Fatalf("Expected version 1, got %d", data.Version)
+	}
+}
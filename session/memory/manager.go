@@ -1,27 +1,139 @@
-// Simple in-memory session manager. Use for testing only.
+// In-memory session manager. Sessions are not shared between processes, so use it for testing
+// or for long-running single-instance servers only.
 package memory
 
 import (
+	"container/list"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/puttsk/go-mcp"
 )
 
 type SessionManager struct {
-	Debug    bool
+	Debug bool
+
+	// Sessions holds the stored sessions by ID and is kept up to date by the manager.
+	// It is guarded by the lock of the manager, so only access it directly while no other goroutine uses the manager,
+	// e.g. to add sessions in tests. Sessions added directly are tracked for expiry from their first use.
 	Sessions map[string]mcp.McpSession
+
+	// Now returns the current time used for expiry. Defaults to time.Now
+	Now func() time.Time
+
+	IdleTTL     time.Duration // Remove sessions not accessed for this duration. Zero disables idle expiry
+	AbsoluteTTL time.Duration // Remove sessions older than this duration. Zero disables absolute expiry
+	MaxSessions int           // Maximum number of sessions. The least recently used session is evicted when exceeded. Zero means unlimited
+
+	mu       sync.Mutex
+	sessions map[string]*list.Element // Session ID to element in lru. Sessions are stored in Sessions
+	lru      *list.List               // Sessions ordered by last access, most recent first
+
+	stop   chan struct{} // Closed by Close to stop the janitor
+	closed bool          // Close was called. Guarded by mu
+	wg     sync.WaitGroup
+}
+
+// entry is a session stored in the manager along with its access times.
+type entry struct {
+	sessionID  string
+	data       map[string]mcp.McpSessionData
+	versions   map[string]int64 // Last version issued for each key, kept after delete so versions never repeat
+	createdAt  time.Time
+	accessedAt time.Time
 }
 
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
 		Sessions: make(map[string]mcp.McpSession),
+		sessions: make(map[string]*list.Element),
+		lru:      list.New(),
 	}
 }
 
+// lazyInit initializes the internal storage. Caller must hold s.mu.
+func (s *SessionManager) lazyInit() {
+	if s.Sessions == nil {
+		s.Sessions = make(map[string]mcp.McpSession)
+	}
+	if s.sessions == nil {
+		s.sessions = make(map[string]*list.Element)
+		s.lru = list.New()
+	}
+}
+
+// track returns the element of a session stored in Sessions, adding it to the LRU index if the session was added
+// to Sessions directly. Elements of sessions removed from Sessions directly are dropped. Caller must hold s.mu.
+func (s *SessionManager) track(sessionID string, now time.Time) (*list.Element, bool) {
+	elem, tracked := s.sessions[sessionID]
+	if _, ok := s.Sessions[sessionID]; !ok {
+		if tracked {
+			s.remove(elem)
+		}
+		return nil, false
+	}
+	if !tracked {
+		elem = s.lru.PushFront(&entry{sessionID: sessionID, createdAt: now, accessedAt: now})
+		s.sessions[sessionID] = elem
+	}
+	return elem, true
+}
+
+func (s *SessionManager) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// expired reports whether the entry has passed its idle or absolute lifetime.
+func (s *SessionManager) expired(e *entry, now time.Time) bool {
+	if s.IdleTTL > 0 && now.Sub(e.accessedAt) >= s.IdleTTL {
+		return true
+	}
+	if s.AbsoluteTTL > 0 && now.Sub(e.createdAt) >= s.AbsoluteTTL {
+		return true
+	}
+	return false
+}
+
+// remove deletes the session and its element. Caller must hold s.mu.
+func (s *SessionManager) remove(elem *list.Element) {
+	e := s.lru.Remove(elem).(*entry)
+	delete(s.sessions, e.sessionID)
+	delete(s.Sessions, e.sessionID)
+}
+
+// lookup returns the live entry of the session and marks it as recently used. Caller must hold s.mu.
+func (s *SessionManager) lookup(sessionID string, now time.Time) (*entry, bool) {
+	s.lazyInit()
+
+	elem, ok := s.track(sessionID, now)
+	if !ok {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if s.expired(e, now) {
+		s.remove(elem)
+		return nil, false
+	}
+
+	e.accessedAt = now
+	s.lru.MoveToFront(elem)
+	return e, true
+}
+
 func (s *SessionManager) GetSession(sessionID string) (mcp.McpSession, bool) {
-	sess, ok := s.Sessions[sessionID]
-	return sess, ok
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lookup(sessionID, s.now()); !ok {
+		return mcp.McpSession{}, false
+	}
+	return s.Sessions[sessionID], true
 }
 
 func (s *SessionManager) CreateSession() (mcp.McpSession, error) {
@@ -35,29 +147,229 @@ func (s *SessionManager) CreateSession() (mcp.McpSession, error) {
 		SessionID: sessID.String(),
 	}
 
-	// Store the session in the map
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lazyInit()
+
+	// Evict least recently used sessions to stay within the limit
+	if s.MaxSessions > 0 {
+		for len(s.Sessions) >= s.MaxSessions && s.lru.Len() > 0 {
+			oldest := s.lru.Back()
+			if s.Debug {
+				log.Printf("Evict session: %s", oldest.Value.(*entry).sessionID)
+			}
+			s.remove(oldest)
+		}
+	}
+
+	// Store the session
+	now := s.now()
 	s.Sessions[sess.SessionID] = sess
+	s.sessions[sess.SessionID] = s.lru.PushFront(&entry{
+		sessionID:  sess.SessionID,
+		createdAt:  now,
+		accessedAt: now,
+	})
 
 	if s.Debug {
-		log.Printf("Session: %#v", s.Sessions)
+		log.Printf("Session created: %#v (%d sessions)", sess, len(s.Sessions))
 	}
 	return sess, nil
 }
 
 func (s *SessionManager) SetSessionInitialized(session mcp.McpSession, init bool) (mcp.McpSession, error) {
-	// Update the session in the map
-	if _, ok := s.Sessions[session.SessionID]; !ok {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lookup(session.SessionID, s.now()); !ok {
 		// Session not found
 		return session, mcp.ErrSessionNotFound
 	}
 
-	newSession := session
+	newSession := s.Sessions[session.SessionID]
 	newSession.Initialized = init
-
 	s.Sessions[session.SessionID] = newSession
 
 	if s.Debug {
-		log.Printf("Update Session: %#v", s.Sessions)
+		log.Printf("Update Session: %#v", newSession)
 	}
 	return newSession, nil
 }
+
+func (s *SessionManager) DeleteSession(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lazyInit()
+
+	elem, ok := s.track(sessionID, s.now())
+	if !ok {
+		// Session not found
+		return mcp.ErrSessionNotFound
+	}
+	s.remove(elem)
+	if s.expired(elem.Value.(*entry), s.now()) {
+		// Expired sessions are not found, as in other calls
+		return mcp.ErrSessionNotFound
+	}
+
+	if s.Debug {
+		log.Printf("Session deleted: %s", sessionID)
+	}
+	return nil
+}
+
+func (s *SessionManager) GetSessionData(session mcp.McpSession, key string) (mcp.McpSessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(session.SessionID, s.now())
+	if !ok {
+		// Session not found
+		return mcp.McpSessionData{}, mcp.ErrSessionNotFound
+	}
+
+	data, ok := e.data[key]
+	if !ok {
+		return mcp.McpSessionData{}, mcp.ErrSessionDataNotFound
+	}
+	return data, nil
+}
+
+func (s *SessionManager) SetSessionData(session mcp.McpSession, data mcp.McpSessionData) (mcp.McpSession, mcp.McpSessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(session.SessionID, s.now())
+	if !ok {
+		// Session not found
+		return session, mcp.McpSessionData{}, mcp.ErrSessionNotFound
+	}
+
+	// Version 0 means the key must not exist
+	if e.data[data.Key].Version != data.Version {
+		return session, mcp.McpSessionData{}, mcp.ErrSessionDataVersionConflict
+	}
+
+	if e.data == nil {
+		e.data = make(map[string]mcp.McpSessionData)
+		e.versions = make(map[string]int64)
+	}
+	// Continue from the last issued version so a deleted and re-created key never reuses a version
+	data.Version = e.versions[data.Key] + 1
+	e.versions[data.Key] = data.Version
+	e.data[data.Key] = data
+
+	if s.Debug {
+		log.Printf("Update session data: %s %s (version %d)", session.SessionID, data.Key, data.Version)
+	}
+	return s.Sessions[session.SessionID], data, nil
+}
+
+func (s *SessionManager) DeleteSessionData(session mcp.McpSession, key string, version int64) (mcp.McpSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(session.SessionID, s.now())
+	if !ok {
+		// Session not found
+		return session, mcp.ErrSessionNotFound
+	}
+
+	data, ok := e.data[key]
+	if !ok {
+		return session, mcp.ErrSessionDataNotFound
+	}
+	if data.Version != version {
+		return session, mcp.ErrSessionDataVersionConflict
+	}
+	delete(e.data, key)
+
+	if s.Debug {
+		log.Printf("Delete session data: %s %s", session.SessionID, key)
+	}
+	return s.Sessions[session.SessionID], nil
+}
+
+// Len returns the number of stored sessions, including expired sessions not yet removed.
+func (s *SessionManager) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.Sessions)
+}
+
+// Sweep removes expired sessions and returns the number of removed sessions.
+func (s *SessionManager) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lazyInit()
+
+	now := s.now()
+	// Track sessions added to or removed from Sessions directly
+	for id := range s.Sessions {
+		s.track(id, now)
+	}
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		s.track(elem.Value.(*entry).sessionID, now)
+		elem = next
+	}
+
+	n := 0
+	for elem := s.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if s.expired(elem.Value.(*entry), now) {
+			s.remove(elem)
+			n++
+		}
+		elem = prev
+	}
+	return n
+}
+
+// StartJanitor runs Sweep in the background at the given interval until Close is called.
+// It does nothing if the manager is already closed or a janitor is already running.
+func (s *SessionManager) StartJanitor(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	stop := s.stop
+
+	// Add under the lock so Close cannot wait before the janitor is counted
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n := s.Sweep(); s.Debug && n > 0 {
+					log.Printf("Session janitor removed %d sessions", n)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the background janitor. Janitors cannot be started after Close.
+func (s *SessionManager) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		if s.stop != nil {
+			close(s.stop)
+		}
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
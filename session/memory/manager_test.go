@@ -0,0 +1,220 @@
+package memory_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/puttsk/go-mcp"
+	"github.com/puttsk/go-mcp/session/memory"
+	"github.com/puttsk/go-mcp/session/sessiontest"
+)
+
+func TestSessionManagerConcurrent(t *testing.T) {
+	manager := memory.NewSessionManager()
+	manager.IdleTTL = time.Minute
+	manager.StartJanitor(time.Millisecond)
+	defer manager.Close()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sess, err := manager.CreateSession()
+			if err != nil {
+				t.Errorf("Failed to create session: %v", err)
+				return
+			}
+			if _, err := manager.SetSessionInitialized(sess, true); err != nil {
+				t.Errorf("Failed to initialize session: %v", err)
+				return
+			}
+			if got, ok := manager.GetSession(sess.SessionID); !ok || !got.Initialized {
+				t.Errorf("Expected initialized session, got %#v (found: %t)", got, ok)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if manager.Len() != 50 {
+		t.Fatalf("Expected 50 sessions, got %d", manager.Len())
+	}
+}
+
+func TestSessionManagerExpiry(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Now()
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	manager := memory.NewSessionManager()
+	manager.IdleTTL = 100 * time.Millisecond
+	manager.AbsoluteTTL = 250 * time.Millisecond
+	manager.Now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// Access keeps the session alive until the absolute lifetime
+	for range 4 {
+		advance(50 * time.Millisecond)
+		if _, ok := manager.GetSession(sess.SessionID); !ok {
+			t.Fatalf("Session expired while in use")
+		}
+	}
+	advance(50 * time.Millisecond)
+	if _, ok := manager.GetSession(sess.SessionID); ok {
+		t.Fatalf("Session did not expire after absolute lifetime")
+	}
+	if _, err := manager.SetSessionInitialized(sess, true); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	// Expired sessions cannot be deleted
+	expired, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	advance(100 * time.Millisecond)
+	if err := manager.DeleteSession(expired.SessionID); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound deleting expired session, got %v", err)
+	}
+
+	// Idle session is removed by the janitor
+	if _, err := manager.CreateSession(); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	advance(100 * time.Millisecond)
+	manager.StartJanitor(time.Millisecond)
+	defer manager.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for manager.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected janitor to remove idle sessions, %d left", manager.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSessionManagerCloseBeforeJanitor(t *testing.T) {
+	manager := memory.NewSessionManager()
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Failed to close manager: %v", err)
+	}
+	manager.StartJanitor(time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		manager.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Close blocked on a janitor started after Close")
+	}
+}
+
+func TestSessionManagerJanitorStartedOnce(t *testing.T) {
+	manager := memory.NewSessionManager()
+	manager.IdleTTL = time.Millisecond
+	manager.StartJanitor(time.Hour)
+	defer manager.Close()
+
+	if _, err := manager.CreateSession(); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// The second janitor is not started, so the idle session is only removed by the hourly sweep
+	manager.StartJanitor(time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if n := manager.Len(); n != 1 {
+		t.Fatalf("Expected the session to be kept until the first janitor runs, %d left", n)
+	}
+}
+
+func TestSessionManagerSessions(t *testing.T) {
+	manager := &memory.SessionManager{
+		Sessions: map[string]mcp.McpSession{"literal": {SessionID: "literal", Initialized: true}},
+	}
+
+	sess, ok := manager.GetSession("literal")
+	if !ok || !sess.Initialized {
+		t.Fatalf("Expected session from struct literal, got %#v (found: %t)", sess, ok)
+	}
+
+	manager = memory.NewSessionManager()
+	manager.Sessions["direct"] = mcp.McpSession{SessionID: "direct"}
+	if _, ok := manager.GetSession("direct"); !ok {
+		t.Fatalf("Expected session added to Sessions")
+	}
+
+	created, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, ok := manager.Sessions[created.SessionID]; !ok {
+		t.Fatalf("Expected created session in Sessions")
+	}
+	if _, err := manager.SetSessionInitialized(created, true); err != nil {
+		t.Fatalf("Failed to initialize session: %v", err)
+	}
+	if !manager.Sessions[created.SessionID].Initialized {
+		t.Fatalf("Expected initialized session in Sessions")
+	}
+
+	// Sessions stays writable after use
+	delete(manager.Sessions, "direct")
+	if _, ok := manager.GetSession("direct"); ok {
+		t.Fatalf("Expected session removed from Sessions to be gone")
+	}
+	if err := manager.DeleteSession(created.SessionID); err != nil {
+		t.Fatalf("Failed to delete session: %v", err)
+	}
+	if n := len(manager.Sessions); n != 0 {
+		t.Fatalf("Expected no sessions, got %d", n)
+	}
+}
+
+func TestSessionManagerEviction(t *testing.T) {
+	manager := memory.NewSessionManager()
+	manager.MaxSessions = 2
+
+	first, _ := manager.CreateSession()
+	second, _ := manager.CreateSession()
+
+	// Touch the first session so the second becomes the least recently used
+	manager.GetSession(first.SessionID)
+
+	third, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if _, ok := manager.GetSession(second.SessionID); ok {
+		t.Fatalf("Least recently used session was not evicted")
+	}
+	for _, sess := range []mcp.McpSession{first, third} {
+		if _, ok := manager.GetSession(sess.SessionID); !ok {
+			t.Fatalf("Session %s evicted unexpectedly", sess.SessionID)
+		}
+	}
+}
+
+func TestSessionManagerData(t *testing.T) {
+	sessiontest.TestSessionData(t, memory.NewSessionManager())
+}
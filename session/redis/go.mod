@@ -0,0 +1,20 @@
+module github.com/puttsk/go-mcp/session/redis
+
+go 1.22
+
+require (
+	github.com/alicebob/miniredis/v2 v2.34.0
+	github.com/google/uuid v1.6.0
+	github.com/puttsk/go-mcp v0.0.0
+	github.com/redis/go-redis/v9 v9.7.3
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20230218143504-906a9b012302 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
+
+// Build against the parent module in this repository. Require its released version when tagging this module
+replace github.com/puttsk/go-mcp => ../..
@@ -0,0 +1,384 @@
+// Redis-backed session manager. Sessions are shared between server instances through Redis
+// and expire after a period of inactivity.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/puttsk/go-mcp"
+	"github.com/redis/go-redis/v9"
+)
+
+const DefaultTTL = 30 * time.Minute             // Default idle lifetime of a session
+const DefaultOperationTimeout = 5 * time.Second // Default time limit of each Redis command
+
+// ErrNoSubscriber is returned by Publish when no instance is subscribed to the session, e.g. its stream is closed.
+var ErrNoSubscriber = errors.New("no subscriber for session")
+
+// Session fields are stored in a Redis hash. The scripts below keep reads and updates atomic
+// and extend the expiry of the session on every access.
+var (
+	createScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'initialized', '0')
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 1
+`)
+
+	getScript = redis.NewScript(`
+local init = redis.call('HGET', KEYS[1], 'initialized')
+if not init then
+	return false
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return init
+`)
+
+	setInitializedScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'initialized', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 1
+`)
+
+	// Session data is stored in the session hash as 'data:<key>' and 'version:<key>' fields.
+	// The version field is kept when the key is deleted so a re-created key never reuses a version.
+	// Data scripts return -1 if the session does not exist, -2 on version conflict and -3 if the key does not exist.
+	getDataScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return redis.call('HMGET', KEYS[1], 'data:' .. ARGV[2], 'version:' .. ARGV[2])
+`)
+
+	setDataScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local version = tonumber(redis.call('HGET', KEYS[1], 'version:' .. ARGV[2]) or '0')
+local current = version
+if redis.call('HEXISTS', KEYS[1], 'data:' .. ARGV[2]) == 0 then
+	current = 0
+end
+if current ~= tonumber(ARGV[3]) then
+	return -2
+end
+redis.call('HSET', KEYS[1], 'data:' .. ARGV[2], ARGV[4], 'version:' .. ARGV[2], version + 1)
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return version + 1
+`)
+
+	deleteDataScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+if redis.call('HEXISTS', KEYS[1], 'data:' .. ARGV[2]) == 0 then
+	return -3
+end
+local version = redis.call('HGET', KEYS[1], 'version:' .. ARGV[2])
+if tonumber(version) ~= tonumber(ARGV[3]) then
+	return -2
+end
+redis.call('HDEL', KEYS[1], 'data:' .. ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 0
+`)
+)
+
+// dataScriptError converts a negative result of a data script to an error.
+func dataScriptError(code int64) error {
+	switch code {
+	case -1:
+		return mcp.ErrSessionNotFound
+	case -2:
+		return mcp.ErrSessionDataVersionConflict
+	case -3:
+		return mcp.ErrSessionDataNotFound
+	default:
+		return nil
+	}
+}
+
+type SessionManager struct {
+	Debug bool
+
+	Client redis.UniversalClient // Redis client
+	Prefix string                // Key prefix, e.g. server name. Allows several servers to share one Redis
+	TTL    time.Duration         // Idle lifetime of a session. Extended on every access. Zero uses DefaultTTL
+
+	// OperationTimeout is the time limit of each Redis command of the session store. Zero uses DefaultOperationTimeout.
+	// The client only applies it if redis.Options.ContextTimeoutEnabled is set, otherwise its read and write timeouts apply.
+	OperationTimeout time.Duration
+}
+
+// NewSessionManager creates a session manager storing sessions in Redis under the given key prefix.
+func NewSessionManager(client redis.UniversalClient, prefix string) *SessionManager {
+	return &SessionManager{
+		Client: client,
+		Prefix: prefix,
+		TTL:    DefaultTTL,
+
+		OperationTimeout: DefaultOperationTimeout,
+	}
+}
+
+// operationContext returns the context of a Redis command limited to OperationTimeout.
+func (s *SessionManager) operationContext() (context.Context, context.CancelFunc) {
+	timeout := s.OperationTimeout
+	if timeout <= 0 {
+		timeout = DefaultOperationTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// ttl returns the idle lifetime of a session.
+func (s *SessionManager) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultTTL
+	}
+	return s.TTL
+}
+
+// key returns the Redis key with the configured prefix.
+func (s *SessionManager) key(kind string, sessionID string) string {
+	if s.Prefix == "" {
+		return kind + ":" + sessionID
+	}
+	return s.Prefix + ":" + kind + ":" + sessionID
+}
+
+// GetSession retrieves a session by its ID. Redis errors are logged and reported as a missing session,
+// use LookupSession to tell them apart.
+func (s *SessionManager) GetSession(sessionID string) (mcp.McpSession, bool) {
+	sess, err := s.LookupSession(sessionID)
+	if err != nil {
+		if !errors.Is(err, mcp.ErrSessionNotFound) {
+			log.Printf("Cannot get session %s: %v", sessionID, err)
+		}
+		return mcp.McpSession{}, false
+	}
+	return sess, true
+}
+
+func (s *SessionManager) LookupSession(sessionID string) (mcp.McpSession, error) {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	init, err := getScript.Run(ctx, s.Client, []string{s.key("session", sessionID)}, s.ttl().Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return mcp.McpSession{}, mcp.ErrSessionNotFound
+	}
+	if err != nil {
+		return mcp.McpSession{}, fmt.Errorf("cannot get session: %w", err)
+	}
+
+	return mcp.McpSession{
+		SessionID:   sessionID,
+		Initialized: init == "1",
+	}, nil
+}
+
+func (s *SessionManager) CreateSession() (mcp.McpSession, error) {
+	// Generate a new UUID for the session ID
+	sessID, err := uuid.NewRandom()
+	if err != nil {
+		return mcp.McpSession{}, err
+	}
+
+	sess := mcp.McpSession{
+		SessionID: sessID.String(),
+	}
+
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	created, err := createScript.Run(ctx, s.Client, []string{s.key("session", sess.SessionID)}, s.ttl().Milliseconds()).Int()
+	if err != nil {
+		return mcp.McpSession{}, fmt.Errorf("cannot store session: %w", err)
+	}
+	if created == 0 {
+		return mcp.McpSession{}, fmt.Errorf("session %s already exists", sess.SessionID)
+	}
+
+	if s.Debug {
+		log.Printf("Session created: %#v", sess)
+	}
+	return sess, nil
+}
+
+func (s *SessionManager) SetSessionInitialized(session mcp.McpSession, init bool) (mcp.McpSession, error) {
+	value := "0"
+	if init {
+		value = "1"
+	}
+
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	updated, err := setInitializedScript.Run(ctx, s.Client, []string{s.key("session", session.SessionID)}, s.ttl().Milliseconds(), value).Int()
+	if err != nil {
+		return session, fmt.Errorf("cannot update session: %w", err)
+	}
+	if updated == 0 {
+		// Session not found
+		return session, mcp.ErrSessionNotFound
+	}
+
+	newSession := session
+	newSession.Initialized = init
+
+	if s.Debug {
+		log.Printf("Update Session: %#v", newSession)
+	}
+	return newSession, nil
+}
+
+func (s *SessionManager) DeleteSession(sessionID string) error {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	n, err := s.Client.Del(ctx, s.key("session", sessionID)).Result()
+	if err != nil {
+		return fmt.Errorf("cannot delete session: %w", err)
+	}
+	if n == 0 {
+		// Session not found
+		return mcp.ErrSessionNotFound
+	}
+
+	if s.Debug {
+		log.Printf("Session deleted: %s", sessionID)
+	}
+	return nil
+}
+
+func (s *SessionManager) GetSessionData(session mcp.McpSession, key string) (mcp.McpSessionData, error) {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	res, err := getDataScript.Run(ctx, s.Client, []string{s.key("session", session.SessionID)}, s.ttl().Milliseconds(), key).Result()
+	if err != nil {
+		return mcp.McpSessionData{}, fmt.Errorf("cannot get session data: %w", err)
+	}
+	if code, ok := res.(int64); ok {
+		return mcp.McpSessionData{}, dataScriptError(code)
+	}
+
+	fields, ok := res.([]any)
+	if !ok || len(fields) != 2 || fields[0] == nil || fields[1] == nil {
+		return mcp.McpSessionData{}, mcp.ErrSessionDataNotFound
+	}
+
+	value, _ := fields[0].(string)
+	version, err := strconv.ParseInt(fmt.Sprint(fields[1]), 10, 64)
+	if err != nil {
+		return mcp.McpSessionData{}, fmt.Errorf("invalid session data version: %w", err)
+	}
+
+	return mcp.McpSessionData{
+		Key:     key,
+		Value:   json.RawMessage(value),
+		Version: version,
+	}, nil
+}
+
+func (s *SessionManager) SetSessionData(session mcp.McpSession, data mcp.McpSessionData) (mcp.McpSession, mcp.McpSessionData, error) {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	version, err := setDataScript.Run(ctx, s.Client, []string{s.key("session", session.SessionID)}, s.ttl().Milliseconds(), data.Key, data.Version, string(data.Value)).Int64()
+	if err != nil {
+		return session, mcp.McpSessionData{}, fmt.Errorf("cannot set session data: %w", err)
+	}
+	if err := dataScriptError(version); err != nil {
+		return session, mcp.McpSessionData{}, err
+	}
+
+	data.Version = version
+
+	if s.Debug {
+		log.Printf("Update session data: %s %s (version %d)", session.SessionID, data.Key, data.Version)
+	}
+	return session, data, nil
+}
+
+func (s *SessionManager) DeleteSessionData(session mcp.McpSession, key string, version int64) (mcp.McpSession, error) {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	code, err := deleteDataScript.Run(ctx, s.Client, []string{s.key("session", session.SessionID)}, s.ttl().Milliseconds(), key, version).Int64()
+	if err != nil {
+		return session, fmt.Errorf("cannot delete session data: %w", err)
+	}
+	if err := dataScriptError(code); err != nil {
+		return session, err
+	}
+
+	if s.Debug {
+		log.Printf("Delete session data: %s %s", session.SessionID, key)
+	}
+	return session, nil
+}
+
+// Publish sends a notification to the instance holding the stream of the given session.
+// The message is encoded as JSON and published on the session's notification channel.
+// StreamTransport publishes messages to sessions of other instances.
+// It returns ErrNoSubscriber if no instance received the message.
+func (s *SessionManager) Publish(ctx context.Context, sessionID string, message any) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("cannot encode message: %w", err)
+	}
+
+	receivers, err := s.Client.Publish(ctx, s.key("notifications", sessionID), payload).Result()
+	if err != nil {
+		return err
+	}
+	if receivers == 0 {
+		return fmt.Errorf("%w: %s", ErrNoSubscriber, sessionID)
+	}
+	return nil
+}
+
+// Subscribe listens for notifications published to the given session.
+// Messages are delivered as raw JSON until the context is cancelled, after which the channel is closed.
+// StreamTransport.Relay subscribes and forwards the messages to the stream of the session.
+func (s *SessionManager) Subscribe(ctx context.Context, sessionID string) (<-chan json.RawMessage, error) {
+	pubsub := s.Client.Subscribe(ctx, s.key("notifications", sessionID))
+
+	// Wait for the subscription to be confirmed so no message published afterwards is lost
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("cannot subscribe to session %s: %w", sessionID, err)
+	}
+
+	messages := make(chan json.RawMessage)
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- json.RawMessage(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
@@ -0,0 +1,179 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/puttsk/go-mcp"
+	sessredis "github.com/puttsk/go-mcp/session/redis"
+	"github.com/puttsk/go-mcp/session/sessiontest"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestManager(t *testing.T) (*sessredis.SessionManager, *miniredis.Miniredis) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return sessredis.NewSessionManager(client, "test_server"), srv
+}
+
+func TestSessionManager(t *testing.T) {
+	manager, srv := newTestManager(t)
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if !srv.Exists("test_server:session:" + sess.SessionID) {
+		t.Fatalf("Session key is not prefixed with server name")
+	}
+
+	if _, err := manager.SetSessionInitialized(sess, true); err != nil {
+		t.Fatalf("Failed to initialize session: %v", err)
+	}
+
+	got, ok := manager.GetSession(sess.SessionID)
+	if !ok || !got.Initialized {
+		t.Fatalf("Expected initialized session, got %#v (found: %t)", got, ok)
+	}
+
+	if _, err := manager.SetSessionInitialized(mcp.McpSession{SessionID: "unknown"}, true); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSessionManagerLookupError(t *testing.T) {
+	manager, srv := newTestManager(t)
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := manager.LookupSession("unknown"); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	// Redis errors are not a missing session
+	srv.SetError("LOADING Redis is loading the dataset in memory")
+	defer srv.SetError("")
+	if _, err := manager.LookupSession(sess.SessionID); err == nil || errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected Redis error, got %v", err)
+	}
+}
+
+func TestSessionManagerOperationTimeout(t *testing.T) {
+	// The server accepts connections but never replies
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), ContextTimeoutEnabled: true, MaxRetries: -1})
+	defer client.Close()
+	manager := sessredis.NewSessionManager(client, "test_server")
+	manager.OperationTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if _, err := manager.LookupSession("blocked"); err == nil || errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected command to time out after OperationTimeout, took %s", elapsed)
+	}
+}
+
+func TestSessionManagerSlidingTTL(t *testing.T) {
+	manager, srv := newTestManager(t)
+	manager.TTL = time.Minute
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// Accessing the session extends its lifetime
+	srv.FastForward(40 * time.Second)
+	if _, ok := manager.GetSession(sess.SessionID); !ok {
+		t.Fatalf("Session expired too early")
+	}
+	srv.FastForward(40 * time.Second)
+	if _, ok := manager.GetSession(sess.SessionID); !ok {
+		t.Fatalf("Session lifetime was not extended on access")
+	}
+
+	srv.FastForward(2 * time.Minute)
+	if _, ok := manager.GetSession(sess.SessionID); ok {
+		t.Fatalf("Idle session did not expire")
+	}
+}
+
+func TestSessionManagerZeroTTL(t *testing.T) {
+	manager, srv := newTestManager(t)
+	manager.TTL = 0
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if ttl := srv.TTL("test_server:session:" + sess.SessionID); ttl != sessredis.DefaultTTL {
+		t.Fatalf("Expected DefaultTTL for zero TTL, got %s", ttl)
+	}
+	if _, ok := manager.GetSession(sess.SessionID); !ok {
+		t.Fatalf("Session with zero TTL expired")
+	}
+}
+
+func TestSessionManagerPubSub(t *testing.T) {
+	manager, _ := newTestManager(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Nobody receives the message before the session is subscribed
+	if err := manager.Publish(ctx, "test-session", map[string]any{"method": "ping"}); !errors.Is(err, sessredis.ErrNoSubscriber) {
+		t.Fatalf("Expected ErrNoSubscriber, got %v", err)
+	}
+
+	messages, err := manager.Subscribe(ctx, "test-session")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if err := manager.Publish(ctx, "test-session", map[string]any{"method": "notifications/tools/list_changed"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	select {
+	case msg := <-messages:
+		if string(msg) != `{"method":"notifications/tools/list_changed"}` {
+			t.Fatalf("Unexpected message: %s", msg)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Message not received")
+	}
+
+	cancel()
+	if _, ok := <-messages; ok {
+		t.Fatalf("Channel not closed after context is cancelled")
+	}
+}
+
+func TestSessionManagerData(t *testing.T) {
+	manager, _ := newTestManager(t)
+	sessiontest.TestSessionData(t, manager)
+}
@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"log"
+	"slices"
+
+	"github.com/puttsk/go-mcp"
+)
+
+// StreamTransport routes server-to-client messages between server instances through Redis pub/sub.
+// Messages to sessions whose stream is held by this instance are sent through the local transport.
+// Other messages are published to the session and delivered by the instance holding the stream,
+// which must call Relay when the stream is opened.
+//
+// ConnectedSessions only returns the sessions of this instance, so notifications/tools/list_changed
+// reaches the sessions of the instance whose tools changed. Responses of the client to server requests,
+// e.g. sampling, must still reach the instance which sent the request.
+type StreamTransport struct {
+	mcp.McpStreamTransportHandler // Transport of this instance
+
+	Sessions *SessionManager // Session manager whose Redis client carries the messages
+}
+
+// NewStreamTransport creates a transport routing messages of local through the Redis client of sessions.
+func NewStreamTransport(local mcp.McpStreamTransportHandler, sessions *SessionManager) *StreamTransport {
+	return &StreamTransport{
+		McpStreamTransportHandler: local,
+		Sessions:                  sessions,
+	}
+}
+
+// SendMessage sends the message through the local transport if the session is connected to this instance,
+// or publishes it to the instance holding the stream otherwise. It returns ErrNoSubscriber if no instance holds the stream.
+func (t *StreamTransport) SendMessage(ctx context.Context, sessionID string, message any) error {
+	if slices.Contains(t.McpStreamTransportHandler.ConnectedSessions(), sessionID) {
+		return t.McpStreamTransportHandler.SendMessage(ctx, sessionID, message)
+	}
+	return t.Sessions.Publish(ctx, sessionID, message)
+}
+
+// IsTerminateSessionRequest forwards to the local transport if it implements mcp.McpTerminateTransportHandler.
+func (t *StreamTransport) IsTerminateSessionRequest(ctx context.Context, request any) bool {
+	if terminate, ok := t.McpStreamTransportHandler.(mcp.McpTerminateTransportHandler); ok {
+		return terminate.IsTerminateSessionRequest(ctx, request)
+	}
+	return false
+}
+
+// Relay forwards messages published to the session to its stream on this instance until ctx is cancelled,
+// e.g. for the lifetime of the stream. It returns once the subscription is active.
+func (t *StreamTransport) Relay(ctx context.Context, sessionID string) error {
+	messages, err := t.Sessions.Subscribe(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for msg := range messages {
+			if err := t.McpStreamTransportHandler.SendMessage(ctx, sessionID, msg); err != nil {
+				log.Printf("Cannot relay message to session %s: %v", sessionID, err)
+			} else if t.Sessions.Debug {
+				log.Printf("Relayed message to session %s", sessionID)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,85 @@
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/puttsk/go-mcp"
+	sessredis "github.com/puttsk/go-mcp/session/redis"
+)
+
+// streamTransport is a stream transport of one server instance recording messages sent to its sessions.
+type streamTransport struct {
+	sessions []string
+	messages chan any
+}
+
+func (t *streamTransport) GetSessionID(ctx context.Context, request any) (string, error) {
+	return "", nil
+}
+
+func (t *streamTransport) ProcessRequest(ctx context.Context, request any) (*mcp.McpRequest, error) {
+	return nil, nil
+}
+
+func (t *streamTransport) ProcessResponse(ctx context.Context, response *mcp.McpResponse) (any, error) {
+	return nil, nil
+}
+
+func (t *streamTransport) SendMessage(ctx context.Context, sessionID string, message any) error {
+	t.messages <- message
+	return nil
+}
+
+func (t *streamTransport) ConnectedSessions() []string {
+	return t.sessions
+}
+
+func TestStreamTransport(t *testing.T) {
+	manager, _ := newTestManager(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Instance A holds the stream of session-a, instance B the stream of session-b
+	localA := &streamTransport{sessions: []string{"session-a"}, messages: make(chan any, 1)}
+	localB := &streamTransport{sessions: []string{"session-b"}, messages: make(chan any, 1)}
+	transportA := sessredis.NewStreamTransport(localA, manager)
+	if err := transportA.Relay(ctx, "session-a"); err != nil {
+		t.Fatalf("Failed to relay: %v", err)
+	}
+
+	serverB, err := mcp.NewMcpServer("test_server", "1.0.0", mcp.McpProtocol2025_30_26)
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	serverB.TransportHandler = sessredis.NewStreamTransport(localB, manager)
+
+	// Notifications to sessions of other instances are published
+	if err := serverB.SendNotification(ctx, "session-a", "notifications/tools/list_changed", nil); err != nil {
+		t.Fatalf("Failed to send notification: %v", err)
+	}
+	select {
+	case msg := <-localA.messages:
+		var notification mcp.McpNotification
+		if err := json.Unmarshal(msg.(json.RawMessage), &notification); err != nil || notification.Method != "notifications/tools/list_changed" {
+			t.Fatalf("Unexpected relayed message: %s (error: %v)", msg, err)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Notification not relayed to the instance holding the stream")
+	}
+
+	// Notifications to local sessions are sent directly
+	if err := serverB.SendNotification(ctx, "session-b", "notifications/tools/list_changed", nil); err != nil {
+		t.Fatalf("Failed to send notification: %v", err)
+	}
+	select {
+	case msg := <-localB.messages:
+		if notification, ok := msg.(mcp.McpNotification); !ok || notification.Method != "notifications/tools/list_changed" {
+			t.Fatalf("Unexpected local message: %#v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Notification not sent to the local stream")
+	}
+}
@@ -0,0 +1,79 @@
+// Conformance tests for session managers. Session manager packages call these from their own tests
+// so every backend implements the same semantics.
+package sessiontest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/puttsk/go-mcp"
+)
+
+// TestSessionData tests the versioned session data store of the session manager.
+func TestSessionData(t *testing.T, manager mcp.McpSessionDataManager) {
+	t.Helper()
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	sess, data, err := manager.SetSessionData(sess, mcp.McpSessionData{Key: "cart", Value: []byte(`["apple"]`)})
+	if err != nil {
+		t.Fatalf("Failed to set session data: %v", err)
+	}
+	if data.Version != 1 {
+		t.Fatalf("Expected version 1, got %d", data.Version)
+	}
+	if _, _, err := manager.SetSessionData(sess, mcp.McpSessionData{Key: "cart", Value: []byte(`[]`)}); !errors.Is(err, mcp.ErrSessionDataVersionConflict) {
+		t.Fatalf("Expected ErrSessionDataVersionConflict, got %v", err)
+	}
+
+	sess, data, err = manager.SetSessionData(sess, mcp.McpSessionData{Key: "cart", Value: []byte(`["apple","pear"]`), Version: 1})
+	if err != nil {
+		t.Fatalf("Failed to update session data: %v", err)
+	}
+	got, err := manager.GetSessionData(sess, "cart")
+	if err != nil {
+		t.Fatalf("Failed to get session data: %v", err)
+	}
+	if string(got.Value) != `["apple","pear"]` || got.Version != 2 {
+		t.Fatalf("Unexpected session data: %s (version %d)", got.Value, got.Version)
+	}
+
+	if _, err := manager.DeleteSessionData(sess, "cart", 1); !errors.Is(err, mcp.ErrSessionDataVersionConflict) {
+		t.Fatalf("Expected ErrSessionDataVersionConflict, got %v", err)
+	}
+	sess, err = manager.DeleteSessionData(sess, "cart", 2)
+	if err != nil {
+		t.Fatalf("Failed to delete session data: %v", err)
+	}
+	if _, err := manager.GetSessionData(sess, "cart"); !errors.Is(err, mcp.ErrSessionDataNotFound) {
+		t.Fatalf("Expected ErrSessionDataNotFound, got %v", err)
+	}
+	if _, err := manager.DeleteSessionData(sess, "cart", 2); !errors.Is(err, mcp.ErrSessionDataNotFound) {
+		t.Fatalf("Expected ErrSessionDataNotFound, got %v", err)
+	}
+
+	// A writer holding the version from before the delete must not overwrite the re-created key
+	if _, _, err := manager.SetSessionData(sess, mcp.McpSessionData{Key: "cart", Value: []byte(`[]`), Version: 2}); !errors.Is(err, mcp.ErrSessionDataVersionConflict) {
+		t.Fatalf("Expected ErrSessionDataVersionConflict, got %v", err)
+	}
+	sess, data, err = manager.SetSessionData(sess, mcp.McpSessionData{Key: "cart", Value: []byte(`["plum"]`)})
+	if err != nil {
+		t.Fatalf("Failed to re-create session data: %v", err)
+	}
+	if data.Version != 3 {
+		t.Fatalf("Expected version 3 for re-created key, got %d", data.Version)
+	}
+	if got, err := manager.GetSessionData(sess, "cart"); err != nil || string(got.Value) != `["plum"]` || got.Version != 3 {
+		t.Fatalf("Unexpected session data: %s (version %d, error %v)", got.Value, got.Version, err)
+	}
+
+	if _, _, err := manager.SetSessionData(mcp.McpSession{SessionID: "unknown"}, data); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if _, err := manager.GetSessionData(mcp.McpSession{SessionID: "unknown"}, "cart"); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+module github.com/puttsk/go-mcp/session/sql
+
+go 1.22
+
+require (
+	github.com/google/uuid v1.6.0
+	github.com/puttsk/go-mcp v0.0.0
+	modernc.org/sqlite v1.30.2
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.19.0 // indirect
+	modernc.org/gc/v3 v3.0.0-20240107210532-573471604cb6 // indirect
+	modernc.org/libc v1.52.1 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
+	modernc.org/strutil v1.2.0 // indirect
+	modernc.org/token v1.1.0 // indirect
+)
+
+// Build against the parent module in this repository. Require its released version when tagging this module
+replace github.com/puttsk/go-mcp => ../..
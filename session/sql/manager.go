@@ -0,0 +1,541 @@
+// SQL session manager built on database/sql. Sessions are stored in a relational database
+// (SQLite or Postgres) so they are durable and can be audited.
+//
+// The caller is responsible for importing the database driver and opening the *sql.DB.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/puttsk/go-mcp"
+)
+
+// Dialect selects the SQL flavour used by the session manager.
+type Dialect string
+
+const DialectSQLite Dialect = "sqlite"     // SQLite dialect
+const DialectPostgres Dialect = "postgres" // Postgres dialect
+
+const DefaultTableName = "mcp_sessions"         // Default name of the session table
+const DefaultTTL = 24 * time.Hour               // Default lifetime of a session
+const DefaultOperationTimeout = 5 * time.Second // Default time limit of each session store operation
+
+type SessionManager struct {
+	Debug bool
+
+	DB        *sql.DB       // Database handle
+	Dialect   Dialect       // SQL dialect of the database
+	TableName string        // Name of the session table
+	TTL       time.Duration // Lifetime of a session
+
+	OperationTimeout time.Duration // Time limit of each session store operation, including its transaction. Zero uses DefaultOperationTimeout
+
+	mu     sync.Mutex    // Guards stop and closed
+	stop   chan struct{} // Closed by Close to stop the sweeper
+	closed bool          // Close was called
+	wg     sync.WaitGroup
+}
+
+// NewSessionManager creates a session manager using the given database and dialect.
+// Call Migrate before using the manager to create or upgrade the schema.
+func NewSessionManager(db *sql.DB, dialect Dialect) *SessionManager {
+	return &SessionManager{
+		DB:        db,
+		Dialect:   dialect,
+		TableName: DefaultTableName,
+		TTL:       DefaultTTL,
+
+		OperationTimeout: DefaultOperationTimeout,
+	}
+}
+
+// operationContext returns the context of a session store operation limited to OperationTimeout.
+func (s *SessionManager) operationContext() (context.Context, context.CancelFunc) {
+	timeout := s.OperationTimeout
+	if timeout <= 0 {
+		timeout = DefaultOperationTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// rebind converts '?' placeholders to the placeholder style of the dialect.
+func (s *SessionManager) rebind(query string) string {
+	if s.Dialect != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// dataTable returns the name of the session data table.
+func (s *SessionManager) dataTable() string {
+	return s.TableName + "_data"
+}
+
+// migrations returns the schema migrations in order. Each entry is applied once in its own transaction.
+// Timestamps are stored as Unix seconds to keep the schema portable between dialects.
+func (s *SessionManager) migrations() []string {
+	return []string{
+		// 1: Session table
+		`CREATE TABLE IF NOT EXISTS ` + s.TableName + ` (
+			session_id  VARCHAR(64) PRIMARY KEY,
+			initialized BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at  BIGINT NOT NULL,
+			updated_at  BIGINT NOT NULL,
+			expires_at  BIGINT NOT NULL
+		)`,
+		// 2: Index for sweeping expired sessions
+		`CREATE INDEX IF NOT EXISTS ` + s.TableName + `_expires_at_idx ON ` + s.TableName + ` (expires_at)`,
+		// 3: Session data table
+		`CREATE TABLE IF NOT EXISTS ` + s.dataTable() + ` (
+			session_id VARCHAR(64) NOT NULL,
+			data_key   VARCHAR(255) NOT NULL,
+			value      TEXT NOT NULL,
+			version    BIGINT NOT NULL,
+			updated_at BIGINT NOT NULL,
+			PRIMARY KEY (session_id, data_key)
+		)`,
+		// 4: Deleted keys are kept with their version so a re-created key never reuses a version
+		`ALTER TABLE ` + s.dataTable() + ` ADD COLUMN deleted BOOLEAN NOT NULL DEFAULT FALSE`,
+	}
+}
+
+// Migrate creates or upgrades the session schema. Applied migrations are recorded in
+// the <TableName>_migrations table so Migrate is safe to call on every start, including
+// from several instances starting together.
+func (s *SessionManager) Migrate(ctx context.Context) error {
+	versionTable := s.TableName + "_migrations"
+
+	_, err := s.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+versionTable+` (
+		version    INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		// Another instance may have created the table concurrently, which Postgres reports as an error
+		if _, selectErr := s.DB.ExecContext(ctx, `SELECT version FROM `+versionTable+` WHERE 1 = 0`); selectErr != nil {
+			return fmt.Errorf("cannot create migration table: %w", err)
+		}
+	}
+
+	var current int
+	err = s.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM `+versionTable).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("cannot read schema version: %w", err)
+	}
+
+	for i, stmt := range s.migrations() {
+		version := i + 1
+		if version <= current {
+			continue
+		}
+		if err := s.applyMigration(ctx, versionTable, version, stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMigration applies a migration in a transaction. The migration is recorded before the statement runs,
+// so an instance migrating concurrently waits for the record, fails on the primary key and skips the migration.
+func (s *SessionManager) applyMigration(ctx context.Context, versionTable string, version int, stmt string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO `+versionTable+` (version, applied_at) VALUES (?, ?)`), version, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		if s.migrationApplied(ctx, versionTable, version) {
+			return nil
+		}
+		return fmt.Errorf("cannot record migration %d: %w", version, err)
+	}
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("cannot apply migration %d: %w", version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		if s.migrationApplied(ctx, versionTable, version) {
+			return nil
+		}
+		return fmt.Errorf("cannot commit migration %d: %w", version, err)
+	}
+
+	if s.Debug {
+		log.Printf("Applied session migration %d", version)
+	}
+	return nil
+}
+
+// migrationApplied reports whether the migration has been recorded, e.g. by another instance.
+func (s *SessionManager) migrationApplied(ctx context.Context, versionTable string, version int) bool {
+	var n int
+	err := s.DB.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM `+versionTable+` WHERE version = ?`), version).Scan(&n)
+	return err == nil && n > 0
+}
+
+// GetSession retrieves a session by its ID. Database errors are logged and reported as a missing session,
+// use LookupSession to tell them apart.
+func (s *SessionManager) GetSession(sessionID string) (mcp.McpSession, bool) {
+	sess, err := s.LookupSession(sessionID)
+	if err != nil {
+		if !errors.Is(err, mcp.ErrSessionNotFound) {
+			log.Printf("Cannot get session %s: %v", sessionID, err)
+		}
+		return mcp.McpSession{}, false
+	}
+	return sess, true
+}
+
+func (s *SessionManager) LookupSession(sessionID string) (mcp.McpSession, error) {
+	sess := mcp.McpSession{
+		SessionID: sessionID,
+	}
+
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	err := s.DB.QueryRowContext(ctx,
+		s.rebind(`SELECT initialized FROM `+s.TableName+` WHERE session_id = ? AND expires_at > ?`),
+		sessionID, time.Now().Unix(),
+	).Scan(&sess.Initialized)
+	if err == sql.ErrNoRows {
+		return mcp.McpSession{}, mcp.ErrSessionNotFound
+	} else if err != nil {
+		return mcp.McpSession{}, fmt.Errorf("cannot get session: %w", err)
+	}
+
+	return sess, nil
+}
+
+func (s *SessionManager) CreateSession() (mcp.McpSession, error) {
+	// Generate a new UUID for the session ID
+	sessID, err := uuid.NewRandom()
+	if err != nil {
+		return mcp.McpSession{}, err
+	}
+
+	sess := mcp.McpSession{
+		SessionID: sessID.String(),
+	}
+
+	now := time.Now()
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	_, err = s.DB.ExecContext(ctx,
+		s.rebind(`INSERT INTO `+s.TableName+` (session_id, initialized, created_at, updated_at, expires_at) VALUES (?, ?, ?, ?, ?)`),
+		sess.SessionID, false, now.Unix(), now.Unix(), now.Add(s.TTL).Unix(),
+	)
+	if err != nil {
+		return mcp.McpSession{}, fmt.Errorf("cannot store session: %w", err)
+	}
+
+	if s.Debug {
+		log.Printf("Session created: %#v", sess)
+	}
+	return sess, nil
+}
+
+func (s *SessionManager) SetSessionInitialized(session mcp.McpSession, init bool) (mcp.McpSession, error) {
+	now := time.Now().Unix()
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	res, err := s.DB.ExecContext(ctx,
+		s.rebind(`UPDATE `+s.TableName+` SET initialized = ?, updated_at = ? WHERE session_id = ? AND expires_at > ?`),
+		init, now, session.SessionID, now,
+	)
+	if err != nil {
+		return session, fmt.Errorf("cannot update session: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return session, err
+	} else if n == 0 {
+		// Session not found
+		return session, mcp.ErrSessionNotFound
+	}
+
+	newSession := session
+	newSession.Initialized = init
+
+	if s.Debug {
+		log.Printf("Update Session: %#v", newSession)
+	}
+	return newSession, nil
+}
+
+func (s *SessionManager) DeleteSession(sessionID string) error {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		s.rebind(`DELETE FROM `+s.TableName+` WHERE session_id = ? AND expires_at > ?`),
+		sessionID, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot delete session: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		// Session not found
+		return mcp.ErrSessionNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM `+s.dataTable()+` WHERE session_id = ?`), sessionID); err != nil {
+		return fmt.Errorf("cannot delete session data: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot delete session: %w", err)
+	}
+
+	if s.Debug {
+		log.Printf("Session deleted: %s", sessionID)
+	}
+	return nil
+}
+
+// sessionExists reports whether the session exists and has not expired.
+func (s *SessionManager) sessionExists(ctx context.Context, tx *sql.Tx, sessionID string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx,
+		s.rebind(`SELECT COUNT(*) FROM `+s.TableName+` WHERE session_id = ? AND expires_at > ?`),
+		sessionID, time.Now().Unix(),
+	).Scan(&n)
+	return n > 0, err
+}
+
+func (s *SessionManager) GetSessionData(session mcp.McpSession, key string) (mcp.McpSessionData, error) {
+	if _, err := s.LookupSession(session.SessionID); err != nil {
+		return mcp.McpSessionData{}, err
+	}
+
+	data := mcp.McpSessionData{
+		Key: key,
+	}
+	var value string
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	err := s.DB.QueryRowContext(ctx,
+		s.rebind(`SELECT value, version FROM `+s.dataTable()+` WHERE session_id = ? AND data_key = ? AND NOT deleted`),
+		session.SessionID, key,
+	).Scan(&value, &data.Version)
+	if err == sql.ErrNoRows {
+		return mcp.McpSessionData{}, mcp.ErrSessionDataNotFound
+	} else if err != nil {
+		return mcp.McpSessionData{}, fmt.Errorf("cannot get session data: %w", err)
+	}
+	data.Value = []byte(value)
+
+	return data, nil
+}
+
+func (s *SessionManager) SetSessionData(session mcp.McpSession, data mcp.McpSessionData) (mcp.McpSession, mcp.McpSessionData, error) {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return session, mcp.McpSessionData{}, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := s.sessionExists(ctx, tx, session.SessionID); err != nil {
+		return session, mcp.McpSessionData{}, fmt.Errorf("cannot get session: %w", err)
+	} else if !ok {
+		// Session not found
+		return session, mcp.McpSessionData{}, mcp.ErrSessionNotFound
+	}
+
+	var res sql.Result
+	now := time.Now().Unix()
+	if data.Version == 0 {
+		// Insert only if the key does not exist, or re-create a deleted key continuing from its version
+		res, err = tx.ExecContext(ctx,
+			s.rebind(`INSERT INTO `+s.dataTable()+` (session_id, data_key, value, version, updated_at) VALUES (?, ?, ?, 1, ?) `+
+				`ON CONFLICT (session_id, data_key) DO UPDATE SET value = excluded.value, version = `+s.dataTable()+`.version + 1, deleted = FALSE, updated_at = excluded.updated_at `+
+				`WHERE `+s.dataTable()+`.deleted`),
+			session.SessionID, data.Key, string(data.Value), now,
+		)
+	} else {
+		// Update only if the version matches
+		res, err = tx.ExecContext(ctx,
+			s.rebind(`UPDATE `+s.dataTable()+` SET value = ?, version = version + 1, updated_at = ? WHERE session_id = ? AND data_key = ? AND version = ? AND NOT deleted`),
+			string(data.Value), now, session.SessionID, data.Key, data.Version,
+		)
+	}
+	if err != nil {
+		return session, mcp.McpSessionData{}, fmt.Errorf("cannot set session data: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return session, mcp.McpSessionData{}, err
+	} else if n == 0 {
+		return session, mcp.McpSessionData{}, mcp.ErrSessionDataVersionConflict
+	}
+
+	if err := tx.QueryRowContext(ctx,
+		s.rebind(`SELECT version FROM `+s.dataTable()+` WHERE session_id = ? AND data_key = ?`),
+		session.SessionID, data.Key,
+	).Scan(&data.Version); err != nil {
+		return session, mcp.McpSessionData{}, fmt.Errorf("cannot set session data: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return session, mcp.McpSessionData{}, fmt.Errorf("cannot set session data: %w", err)
+	}
+
+	if s.Debug {
+		log.Printf("Update session data: %s %s (version %d)", session.SessionID, data.Key, data.Version)
+	}
+	return session, data, nil
+}
+
+func (s *SessionManager) DeleteSessionData(session mcp.McpSession, key string, version int64) (mcp.McpSession, error) {
+	ctx, cancel := s.operationContext()
+	defer cancel()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return session, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := s.sessionExists(ctx, tx, session.SessionID); err != nil {
+		return session, fmt.Errorf("cannot get session: %w", err)
+	} else if !ok {
+		// Session not found
+		return session, mcp.ErrSessionNotFound
+	}
+
+	var current int64
+	err = tx.QueryRowContext(ctx,
+		s.rebind(`SELECT version FROM `+s.dataTable()+` WHERE session_id = ? AND data_key = ? AND NOT deleted`),
+		session.SessionID, key,
+	).Scan(&current)
+	if err == sql.ErrNoRows {
+		return session, mcp.ErrSessionDataNotFound
+	} else if err != nil {
+		return session, fmt.Errorf("cannot get session data: %w", err)
+	}
+	if current != version {
+		return session, mcp.ErrSessionDataVersionConflict
+	}
+
+	res, err := tx.ExecContext(ctx,
+		s.rebind(`UPDATE `+s.dataTable()+` SET value = '', deleted = TRUE, updated_at = ? WHERE session_id = ? AND data_key = ? AND version = ? AND NOT deleted`),
+		time.Now().Unix(), session.SessionID, key, version,
+	)
+	if err != nil {
+		return session, fmt.Errorf("cannot delete session data: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return session, err
+	} else if n == 0 {
+		// Deleted concurrently
+		return session, mcp.ErrSessionDataNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return session, fmt.Errorf("cannot delete session data: %w", err)
+	}
+
+	if s.Debug {
+		log.Printf("Delete session data: %s %s", session.SessionID, key)
+	}
+	return session, nil
+}
+
+// Sweep deletes expired sessions and their data and returns the number of removed sessions.
+func (s *SessionManager) Sweep(ctx context.Context) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	_, err = tx.ExecContext(ctx,
+		s.rebind(`DELETE FROM `+s.dataTable()+` WHERE session_id IN (SELECT session_id FROM `+s.TableName+` WHERE expires_at <= ?)`),
+		now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("cannot sweep session data: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM `+s.TableName+` WHERE expires_at <= ?`), now)
+	if err != nil {
+		return 0, fmt.Errorf("cannot sweep sessions: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("cannot sweep sessions: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// StartSweeper runs Sweep in the background at the given interval until Close is called.
+// It does nothing if the manager is already closed or a sweeper is already running.
+func (s *SessionManager) StartSweeper(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	stop := s.stop
+
+	// Add under the lock so Close cannot wait before the sweeper is counted
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				n, err := s.Sweep(context.Background())
+				if err != nil {
+					log.Printf("Session sweeper: %v", err)
+				} else if s.Debug && n > 0 {
+					log.Printf("Session sweeper removed %d sessions", n)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the background sweeper. Sweepers cannot be started after Close. It does not close the database.
+func (s *SessionManager) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		if s.stop != nil {
+			close(s.stop)
+		}
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,193 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/puttsk/go-mcp"
+	"github.com/puttsk/go-mcp/session/sessiontest"
+	sesssql "github.com/puttsk/go-mcp/session/sql"
+	_ "modernc.org/sqlite"
+)
+
+func newTestManager(t *testing.T) *sesssql.SessionManager {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	manager := sesssql.NewSessionManager(db, sesssql.DialectSQLite)
+	if err := manager.Migrate(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	// Migrations must be idempotent
+	if err := manager.Migrate(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate twice: %v", err)
+	}
+	return manager
+}
+
+func TestSessionManagerConcurrentMigrate(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "sessions.db")+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// Instances starting together migrate the same database
+	errs := make(chan error, 4)
+	for range 4 {
+		go func() {
+			errs <- sesssql.NewSessionManager(db, sesssql.DialectSQLite).Migrate(context.Background())
+		}()
+	}
+	for range 4 {
+		if err := <-errs; err != nil {
+			t.Fatalf("Failed to migrate concurrently: %v", err)
+		}
+	}
+
+	manager := sesssql.NewSessionManager(db, sesssql.DialectSQLite)
+	if _, err := manager.CreateSession(); err != nil {
+		t.Fatalf("Failed to create session after migration: %v", err)
+	}
+}
+
+func TestSessionManagerCloseBeforeSweeper(t *testing.T) {
+	manager := newTestManager(t)
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Failed to close manager: %v", err)
+	}
+	manager.StartSweeper(time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		manager.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Close blocked on a sweeper started after Close")
+	}
+}
+
+func TestSessionManagerSweeperStartedOnce(t *testing.T) {
+	manager := newTestManager(t)
+	manager.TTL = -time.Second
+	manager.StartSweeper(time.Hour)
+	defer manager.Close()
+
+	if _, err := manager.CreateSession(); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// The second sweeper is not started, so the expired session is only removed by the hourly sweep
+	manager.StartSweeper(time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	n, err := manager.Sweep(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to sweep: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Expected the expired session to be kept until the first sweeper runs, swept %d", n)
+	}
+}
+
+func TestSessionManager(t *testing.T) {
+	manager := newTestManager(t)
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	got, ok := manager.GetSession(sess.SessionID)
+	if !ok || got.Initialized {
+		t.Fatalf("Expected uninitialized session, got %#v (found: %t)", got, ok)
+	}
+
+	if _, err := manager.SetSessionInitialized(sess, true); err != nil {
+		t.Fatalf("Failed to initialize session: %v", err)
+	}
+	got, ok = manager.GetSession(sess.SessionID)
+	if !ok || !got.Initialized {
+		t.Fatalf("Expected initialized session, got %#v (found: %t)", got, ok)
+	}
+
+	if _, err := manager.SetSessionInitialized(mcp.McpSession{SessionID: "unknown"}, true); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSessionManagerOperationTimeout(t *testing.T) {
+	manager := newTestManager(t)
+	manager.OperationTimeout = 50 * time.Millisecond
+
+	// Hold the only connection so operations wait for it
+	tx, err := manager.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := manager.CreateSession(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := manager.LookupSession("blocked"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSessionManagerLookupError(t *testing.T) {
+	manager := newTestManager(t)
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := manager.LookupSession("unknown"); !errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	// A closed database is not a missing session
+	manager.DB.Close()
+	if _, err := manager.LookupSession(sess.SessionID); err == nil || errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected database error, got %v", err)
+	}
+	if _, err := manager.GetSessionData(sess, "cart"); err == nil || errors.Is(err, mcp.ErrSessionNotFound) {
+		t.Fatalf("Expected database error, got %v", err)
+	}
+}
+
+func TestSessionManagerSweep(t *testing.T) {
+	manager := newTestManager(t)
+	manager.TTL = -time.Second
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, ok := manager.GetSession(sess.SessionID); ok {
+		t.Fatalf("Expired session found")
+	}
+
+	n, err := manager.Sweep(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to sweep: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Expected 1 swept session, got %d", n)
+	}
+}
+
+func TestSessionManagerData(t *testing.T) {
+	sessiontest.TestSessionData(t, newTestManager(t))
+}
@@ -0,0 +1,310 @@
+// Stateless session manager. The session ID is an HMAC-signed token carrying the session state,
+// so no storage is needed and sessions are valid on every server instance sharing the signing keys.
+//
+// Every change of the session state, including the session data store, issues a new token which is
+// returned to the client in the Mcp-Session-Id response header. McpSession.StableID keeps the same value
+// for all tokens of a session. The token is signed but not encrypted,
+// so do not store secrets in the session data store. Earlier tokens of a session stay valid until they expire,
+// so a client replaying an earlier token rolls back the session data and its versions.
+//
+// The whole session data store, including the roots cached by mcp.ListRoots, is carried in the token.
+// API gateways and proxies typically reject headers larger than 8-16 KB, so tokens are limited to MaxTokenSize
+// (DefaultMaxTokenSize by default) and changes exceeding it fail with ErrTokenTooLarge. Keep session data small.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/puttsk/go-mcp"
+)
+
+const DefaultTTL = 24 * time.Hour // Default lifetime of a session
+const DefaultMaxTokenSize = 4096  // Default maximum size of an encoded token in bytes
+const MinKeySize = 32             // Minimum size of a signing secret in bytes
+
+// ErrTokenTooLarge is returned when a change of the session would issue a token larger than MaxTokenSize.
+var ErrTokenTooLarge = errors.New("session token too large")
+
+// ErrInvalidKey is returned by NewSessionManager when no key is given or a secret is shorter than MinKeySize.
+var ErrInvalidKey = errors.New("invalid session token key")
+
+// Key is a secret used to sign session tokens.
+type Key struct {
+	ID     string // Key ID embedded in tokens to select the key when validating
+	Secret []byte // HMAC-SHA256 secret
+}
+
+type SessionManager struct {
+	Debug bool
+
+	// Keys used to sign and validate tokens. The first key signs new tokens and all keys are accepted when validating.
+	// To rotate keys, add the new key at the front and remove the old key once its tokens have expired.
+	Keys []Key
+	TTL  time.Duration // Lifetime of a session. Reissued tokens keep the original expiry
+
+	MaxTokenSize int // Maximum size of an encoded token in bytes. Zero uses DefaultMaxTokenSize
+}
+
+// claims is the session state carried in the token.
+type claims struct {
+	KeyID       string                     `json:"kid"`            // ID of the signing key
+	ID          string                     `json:"sid"`            // Stable session ID
+	Initialized bool                       `json:"init"`           // Session initialized
+	ExpiresAt   int64                      `json:"exp"`            // Expiry in Unix seconds
+	Data        map[string]json.RawMessage `json:"data,omitempty"` // Session data store
+	Versions    map[string]int64           `json:"ver,omitempty"`  // Versions of session data, kept after delete so versions never repeat
+}
+
+// NewSessionManager creates a session manager signing tokens with the given keys.
+// It returns ErrInvalidKey if no key is given or a secret is shorter than MinKeySize.
+func NewSessionManager(keys ...Key) (*SessionManager, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no key", ErrInvalidKey)
+	}
+	for _, key := range keys {
+		if len(key.Secret) < MinKeySize {
+			return nil, fmt.Errorf("%w: secret of key %q is %d bytes, at least %d bytes are required", ErrInvalidKey, key.ID, len(key.Secret), MinKeySize)
+		}
+	}
+
+	return &SessionManager{
+		Keys: keys,
+		TTL:  DefaultTTL,
+	}, nil
+}
+
+// sign computes the signature of the encoded payload.
+func sign(key Key, payload string) string {
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue encodes and signs the claims with the current signing key.
+func (s *SessionManager) issue(c claims) (string, error) {
+	if len(s.Keys) == 0 {
+		return "", fmt.Errorf("no signing key")
+	}
+	key := s.Keys[0]
+	c.KeyID = key.ID
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode session token: %w", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	token := payload + "." + sign(key, payload)
+
+	maxSize := s.MaxTokenSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxTokenSize
+	}
+	if len(token) > maxSize {
+		return "", fmt.Errorf("%w: %d bytes exceeds %d bytes", ErrTokenTooLarge, len(token), maxSize)
+	}
+	return token, nil
+}
+
+// parse validates the token and returns its claims.
+func (s *SessionManager) parse(token string) (claims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims{}, fmt.Errorf("malformed session token")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return claims{}, fmt.Errorf("malformed session token: %w", err)
+	}
+	var c claims
+	if err := json.Unmarshal(data, &c); err != nil {
+		return claims{}, fmt.Errorf("malformed session token: %w", err)
+	}
+
+	valid := false
+	for _, key := range s.Keys {
+		if key.ID == c.KeyID {
+			valid = hmac.Equal([]byte(signature), []byte(sign(key, payload)))
+			break
+		}
+	}
+	if !valid {
+		return claims{}, fmt.Errorf("invalid session token signature")
+	}
+
+	if c.ExpiresAt <= time.Now().Unix() {
+		return claims{}, fmt.Errorf("session token expired")
+	}
+
+	return c, nil
+}
+
+// session converts the claims to a session with a newly issued token.
+func (s *SessionManager) session(c claims) (mcp.McpSession, error) {
+	token, err := s.issue(c)
+	if err != nil {
+		return mcp.McpSession{}, err
+	}
+
+	return mcp.McpSession{
+		SessionID:   token,
+		Initialized: c.Initialized,
+		StableID:    c.ID,
+	}, nil
+}
+
+func (s *SessionManager) GetSession(sessionID string) (mcp.McpSession, bool) {
+	c, err := s.parse(sessionID)
+	if err != nil {
+		if s.Debug {
+			log.Printf("Cannot get session: %v", err)
+		}
+		return mcp.McpSession{}, false
+	}
+
+	return mcp.McpSession{
+		SessionID:   sessionID,
+		Initialized: c.Initialized,
+		StableID:    c.ID,
+	}, true
+}
+
+func (s *SessionManager) CreateSession() (mcp.McpSession, error) {
+	// Generate a new UUID for the session ID
+	sessID, err := uuid.NewRandom()
+	if err != nil {
+		return mcp.McpSession{}, err
+	}
+
+	sess, err := s.session(claims{
+		ID:        sessID.String(),
+		ExpiresAt: time.Now().Add(s.TTL).Unix(),
+	})
+	if err != nil {
+		return mcp.McpSession{}, err
+	}
+
+	if s.Debug {
+		log.Printf("Session created: %s", sessID)
+	}
+	return sess, nil
+}
+
+func (s *SessionManager) SetSessionInitialized(session mcp.McpSession, init bool) (mcp.McpSession, error) {
+	c, err := s.parse(session.SessionID)
+	if err != nil {
+		// Session not found
+		return session, mcp.ErrSessionNotFound
+	}
+
+	c.Initialized = init
+	newSession, err := s.session(c)
+	if err != nil {
+		return session, err
+	}
+
+	if s.Debug {
+		log.Printf("Update Session: %s (initialized: %t)", c.ID, init)
+	}
+	return newSession, nil
+}
+
+// DeleteSession returns ErrSessionTerminationNotSupported for valid tokens.
+// Tokens cannot be revoked, so the session remains valid until it expires.
+func (s *SessionManager) DeleteSession(sessionID string) error {
+	if _, err := s.parse(sessionID); err != nil {
+		// Session not found
+		return mcp.ErrSessionNotFound
+	}
+	return mcp.ErrSessionTerminationNotSupported
+}
+
+func (s *SessionManager) GetSessionData(session mcp.McpSession, key string) (mcp.McpSessionData, error) {
+	c, err := s.parse(session.SessionID)
+	if err != nil {
+		// Session not found
+		return mcp.McpSessionData{}, mcp.ErrSessionNotFound
+	}
+
+	value, ok := c.Data[key]
+	if !ok {
+		return mcp.McpSessionData{}, mcp.ErrSessionDataNotFound
+	}
+	return mcp.McpSessionData{
+		Key:     key,
+		Value:   value,
+		Version: c.Versions[key],
+	}, nil
+}
+
+func (s *SessionManager) SetSessionData(session mcp.McpSession, data mcp.McpSessionData) (mcp.McpSession, mcp.McpSessionData, error) {
+	c, err := s.parse(session.SessionID)
+	if err != nil {
+		// Session not found
+		return session, mcp.McpSessionData{}, mcp.ErrSessionNotFound
+	}
+
+	// Version 0 means the key must not exist
+	var current int64
+	if _, ok := c.Data[data.Key]; ok {
+		current = c.Versions[data.Key]
+	}
+	if current != data.Version {
+		return session, mcp.McpSessionData{}, mcp.ErrSessionDataVersionConflict
+	}
+
+	if c.Data == nil {
+		c.Data = make(map[string]json.RawMessage)
+	}
+	if c.Versions == nil {
+		c.Versions = make(map[string]int64)
+	}
+	data.Version = c.Versions[data.Key] + 1
+	c.Data[data.Key] = data.Value
+	c.Versions[data.Key] = data.Version
+
+	newSession, err := s.session(c)
+	if err != nil {
+		return session, mcp.McpSessionData{}, err
+	}
+
+	if s.Debug {
+		log.Printf("Update session data: %s %s (version %d)", c.ID, data.Key, data.Version)
+	}
+	return newSession, data, nil
+}
+
+func (s *SessionManager) DeleteSessionData(session mcp.McpSession, key string, version int64) (mcp.McpSession, error) {
+	c, err := s.parse(session.SessionID)
+	if err != nil {
+		// Session not found
+		return session, mcp.ErrSessionNotFound
+	}
+
+	if _, ok := c.Data[key]; !ok {
+		return session, mcp.ErrSessionDataNotFound
+	}
+	if c.Versions[key] != version {
+		return session, mcp.ErrSessionDataVersionConflict
+	}
+	delete(c.Data, key)
+
+	newSession, err := s.session(c)
+	if err != nil {
+		return session, err
+	}
+
+	if s.Debug {
+		log.Printf("Delete session data: %s %s", c.ID, key)
+	}
+	return newSession, nil
+}
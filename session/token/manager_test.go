@@ -0,0 +1,248 @@
+package token_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/puttsk/go-mcp"
+	"github.com/puttsk/go-mcp/session/sessiontest"
+	"github.com/puttsk/go-mcp/session/token"
+	"github.com/puttsk/go-mcp/transport/awslambda"
+)
+
+var oldKey = token.Key{ID: "k1", Secret: []byte("old-secret-of-at-least-32-bytes!")}
+var newKey = token.Key{ID: "k2", Secret: []byte("new-secret-of-at-least-32-bytes!")}
+
+// newManager creates a session manager signing tokens with the given keys.
+func newManager(t *testing.T, keys ...token.Key) *token.SessionManager {
+	t.Helper()
+	manager, err := token.NewSessionManager(keys...)
+	if err != nil {
+		t.Fatalf("Failed to create session manager: %v", err)
+	}
+	return manager
+}
+
+func TestSessionManager(t *testing.T) {
+	manager := newManager(t, oldKey)
+
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, ok := manager.GetSession(sess.SessionID); !ok {
+		t.Fatalf("Session token is not valid")
+	}
+
+	initialized, err := manager.SetSessionInitialized(sess, true)
+	if err != nil {
+		t.Fatalf("Failed to initialize session: %v", err)
+	}
+	if initialized.SessionID == sess.SessionID {
+		t.Fatalf("Token was not reissued")
+	}
+	if got, ok := manager.GetSession(initialized.SessionID); !ok || !got.Initialized {
+		t.Fatalf("Expected initialized session, got %#v (found: %t)", got, ok)
+	}
+	if initialized.StableSessionID() != sess.StableSessionID() || sess.StableSessionID() == sess.SessionID {
+		t.Fatalf("Expected stable session ID across tokens, got %s and %s", sess.StableSessionID(), initialized.StableSessionID())
+	}
+
+	// Tokens cannot be revoked
+	if err := manager.DeleteSession(initialized.SessionID); !errors.Is(err, mcp.ErrSessionTerminationNotSupported) {
+		t.Fatalf("Expected ErrSessionTerminationNotSupported, got %v", err)
+	}
+
+	// Tampered token
+	if _, ok := manager.GetSession(initialized.SessionID + "x"); ok {
+		t.Fatalf("Tampered token accepted")
+	}
+
+	// Expired token
+	manager.TTL = -time.Second
+	expired, _ := manager.CreateSession()
+	if _, ok := manager.GetSession(expired.SessionID); ok {
+		t.Fatalf("Expired token accepted")
+	}
+}
+
+func TestSessionManagerKeyRotation(t *testing.T) {
+	sess, err := newManager(t, oldKey).CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	rotated := newManager(t, newKey, oldKey)
+	if _, ok := rotated.GetSession(sess.SessionID); !ok {
+		t.Fatalf("Token signed with previous key rejected")
+	}
+
+	// Reissued tokens are signed with the new key
+	sess, err = rotated.SetSessionInitialized(sess, true)
+	if err != nil {
+		t.Fatalf("Failed to initialize session: %v", err)
+	}
+	if _, ok := newManager(t, newKey).GetSession(sess.SessionID); !ok {
+		t.Fatalf("Reissued token not signed with new key")
+	}
+
+	if _, ok := newManager(t, newKey).GetSession(mustCreate(t, newManager(t, oldKey)).SessionID); ok {
+		t.Fatalf("Token signed with removed key accepted")
+	}
+}
+
+func TestSessionManagerData(t *testing.T) {
+	sessiontest.TestSessionData(t, newManager(t, oldKey))
+}
+
+func TestSessionManagerInvalidKeys(t *testing.T) {
+	if _, err := token.NewSessionManager(); !errors.Is(err, token.ErrInvalidKey) {
+		t.Fatalf("Expected ErrInvalidKey without keys, got %v", err)
+	}
+	short := token.Key{ID: "short", Secret: []byte("secret")}
+	if _, err := token.NewSessionManager(oldKey, short); !errors.Is(err, token.ErrInvalidKey) {
+		t.Fatalf("Expected ErrInvalidKey for short secret, got %v", err)
+	}
+}
+
+func TestSessionManagerMaxTokenSize(t *testing.T) {
+	manager := newManager(t, oldKey)
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	large := []byte(`"` + strings.Repeat("x", token.DefaultMaxTokenSize) + `"`)
+	if _, _, err := manager.SetSessionData(sess, mcp.McpSessionData{Key: "large", Value: large}); !errors.Is(err, token.ErrTokenTooLarge) {
+		t.Fatalf("Expected ErrTokenTooLarge, got %v", err)
+	}
+	if _, err := manager.GetSessionData(sess, "large"); !errors.Is(err, mcp.ErrSessionDataNotFound) {
+		t.Fatalf("Expected rejected data not to be stored, got %v", err)
+	}
+
+	// The limit is configurable
+	manager.MaxTokenSize = 2 * token.DefaultMaxTokenSize
+	if _, _, err := manager.SetSessionData(sess, mcp.McpSessionData{Key: "large", Value: large}); err != nil {
+		t.Fatalf("Failed to set session data within MaxTokenSize: %v", err)
+	}
+}
+
+func TestSessionManagerReissueHeader(t *testing.T) {
+	server, err := mcp.NewMcpServer("test_server", "1.0.0", mcp.McpProtocol2025_30_26)
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = newManager(t, oldKey)
+
+	send := func(sid string, body string) string {
+		req := events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{},
+			Body:       body,
+		}
+		if sid != "" {
+			req.Headers["Mcp-Session-Id"] = sid
+		}
+		resp, err := server.ProcessRequest(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("Failed to process request: %v", err)
+		}
+		return resp.(events.APIGatewayProxyResponse).Headers["Mcp-Session-Id"]
+	}
+
+	sid := send("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"clientInfo":{"name":"test-client","version":"1.0"}}}`)
+	next := send(sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if next == sid {
+		t.Fatalf("Token was not reissued after initialization")
+	}
+
+	sess, ok := server.SessionManager.GetSession(next)
+	if !ok || !sess.Initialized {
+		t.Fatalf("Expected initialized session in reissued token, got %#v (found: %t)", sess, ok)
+	}
+
+	ctx := mcp.SetSessionManagerInContext(mcp.SetSessionInContext(context.TODO(), sess), server.SessionManager)
+	client, err := mcp.GetSessionClientFromContext(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get session client: %v", err)
+	}
+	if client.ClientInfo.Name != "test-client" || client.ProtocolVersion != mcp.McpProtocol2025_30_26 {
+		t.Fatalf("Unexpected session client: %#v", client)
+	}
+
+	// Termination is refused with HTTP 405
+	resp, err := server.ProcessRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodDelete,
+		Headers:    map[string]string{"Mcp-Session-Id": next},
+	})
+	if err != nil {
+		t.Fatalf("Failed to process request: %v", err)
+	}
+	if code := resp.(events.APIGatewayProxyResponse).StatusCode; code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405, got %d", code)
+	}
+}
+
+func TestSessionManagerOversizedClient(t *testing.T) {
+	server, err := mcp.NewMcpServer("test_server", "1.0.0", mcp.McpProtocol2025_30_26)
+	if err != nil {
+		t.Fatalf("Failed to create MCP server: %v", err)
+	}
+	server.TransportHandler = &awslambda.TransportHandler{}
+	server.SessionManager = newManager(t, oldKey)
+
+	send := func(sid string, body string) events.APIGatewayProxyResponse {
+		req := events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{},
+			Body:       body,
+		}
+		if sid != "" {
+			req.Headers["Mcp-Session-Id"] = sid
+		}
+		resp, err := server.ProcessRequest(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("Failed to process request: %v", err)
+		}
+		return resp.(events.APIGatewayProxyResponse)
+	}
+
+	// The client record does not fit in the token, so initialize succeeds without it
+	name := strings.Repeat("x", token.DefaultMaxTokenSize)
+	resp := send("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"`+name+`","version":"1.0"}}}`)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Body, `"protocolVersion":"2025-03-26"`) {
+		t.Fatalf("Failed to initialize with oversized client info: %d %s", resp.StatusCode, resp.Body)
+	}
+	sid := resp.Headers["Mcp-Session-Id"]
+	if len(sid) > token.DefaultMaxTokenSize {
+		t.Fatalf("Token exceeds MaxTokenSize: %d bytes", len(sid))
+	}
+
+	resp = send(sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status %d for initialized notification, got %d", http.StatusAccepted, resp.StatusCode)
+	}
+	sess, ok := server.SessionManager.GetSession(resp.Headers["Mcp-Session-Id"])
+	if !ok || !sess.Initialized {
+		t.Fatalf("Expected initialized session, got %#v (found: %t)", sess, ok)
+	}
+
+	ctx := mcp.SetSessionManagerInContext(mcp.SetSessionInContext(context.TODO(), sess), server.SessionManager)
+	if _, err := mcp.GetSessionClientFromContext(ctx); !errors.Is(err, mcp.ErrSessionDataNotFound) {
+		t.Fatalf("Expected no session client, got %v", err)
+	}
+}
+
+func mustCreate(t *testing.T, manager *token.SessionManager) mcp.McpSession {
+	sess, err := manager.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	return sess
+}
@@ -1,15 +1,36 @@
 package mcp
 
 import (
+	"context"
 	"encoding/base64"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type McpToolDescriptor struct {
-	Name        string             `json:"name"`        // Name of the tool
-	Description string             `json:"description"` // Description of the tool
-	InputSchema McpToolInputSchema `json:"inputSchema"` // Input schema of the tool
+	Name        string              `json:"name"`                  // Name of the tool
+	Title       string              `json:"title,omitempty"`       // Human-readable title of the tool
+	Description string              `json:"description"`           // Description of the tool
+	InputSchema McpToolInputSchema  `json:"inputSchema"`           // Input schema of the tool
+	Annotations *McpToolAnnotations `json:"annotations,omitempty"` // Hints about the behavior of the tool
+}
+
+// McpToolAnnotations describes the behavior of a tool to the client, e.g. to auto-approve read-only tools
+// or to warn before destructive ones. Annotations are hints and clients should not rely on them for security.
+// Unset hints use the defaults of the protocol.
+// See. https://modelcontextprotocol.io/specification/2025-03-26/server/tools#tool-annotations
+type McpToolAnnotations struct {
+	Title           string `json:"title,omitempty"`           // Human-readable title of the tool
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`    // Tool does not modify its environment. Default: false
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"` // Tool may perform destructive updates. Only meaningful if not read-only. Default: true
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`  // Repeated calls with the same arguments have no additional effect. Default: false
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`   // Tool interacts with external entities. Default: true
+}
+
+// Bool returns a pointer to v, for setting hints of McpToolAnnotations.
+func Bool(v bool) *bool {
+	return &v
 }
 
 type McpToolInputSchema struct {
@@ -20,10 +41,40 @@ type McpToolInputSchema struct {
 }
 
 type McpToolOutput struct {
-	Type     McpToolOutputType `json:"type"`               // Type of the output
-	Text     string            `json:"text,omitempty"`     // Text of the output
-	Data     string            `json:"data,omitempty"`     // Data of the output
-	MimeType string            `json:"mimeType,omitempty"` // Mime type of the output
+	Type     McpToolOutputType    `json:"type"`               // Type of the output
+	Text     string               `json:"text,omitempty"`     // Text of the output
+	Data     string               `json:"data,omitempty"`     // Data of the output
+	MimeType string               `json:"mimeType,omitempty"` // Mime type of the output
+	Resource *McpResourceContents `json:"resource,omitempty"` // Embedded resource of the output
+}
+
+// McpResourceContents is the contents of a resource embedded in a tool output.
+// Either Text or Blob is set.
+type McpResourceContents struct {
+	URI      string `json:"uri"`                // URI of the resource
+	MimeType string `json:"mimeType,omitempty"` // Mime type of the resource
+	Text     string `json:"text,omitempty"`     // Text contents
+	Blob     string `json:"blob,omitempty"`     // Base64 encoded binary contents
+}
+
+// TextContent creates a text tool output.
+func TextContent(text string) McpToolOutput {
+	return McpToolOutput{Type: McpToolOutputTypeText, Text: text}
+}
+
+// ImageContent creates an image tool output.
+func ImageContent(img McpImage) McpToolOutput {
+	return McpToolOutput{Type: McpToolOutputTypeImage, Data: img.Data, MimeType: string(img.MimeType)}
+}
+
+// AudioContent creates an audio tool output.
+func AudioContent(audio McpAudio) McpToolOutput {
+	return McpToolOutput{Type: McpToolOutputTypeAudio, Data: audio.Data, MimeType: string(audio.MimeType)}
+}
+
+// ResourceContent creates a tool output embedding a resource.
+func ResourceContent(resource McpResourceContents) McpToolOutput {
+	return McpToolOutput{Type: McpToolOutputTypeResource, Resource: &resource}
 }
 
 func (o McpToolOutput) String() string {
@@ -33,6 +84,13 @@ func (o McpToolOutput) String() string {
 		return "\"" + o.Text + "\"" + " (" + string(o.Type) + ")"
 	case McpToolOutputTypeImage:
 		return o.MimeType + " (image)"
+	case McpToolOutputTypeAudio:
+		return o.MimeType + " (audio)"
+	case McpToolOutputTypeResource:
+		if o.Resource == nil {
+			return "(resource)"
+		}
+		return o.Resource.URI + " (resource)"
 	default:
 		return o.MimeType + " (data)"
 	}
@@ -43,14 +101,25 @@ type McpToolOutputType string
 
 const McpToolOutputTypeText McpToolOutputType = "text"
 const McpToolOutputTypeImage McpToolOutputType = "image"
+const McpToolOutputTypeAudio McpToolOutputType = "audio"
+const McpToolOutputTypeResource McpToolOutputType = "resource"
 const McpToolOutputTypeError McpToolOutputType = "error"
 
 type McpTool struct {
-	Name        string             // Name of the tool
-	Description string             // Description of the tool
-	Function    any                // Function to be called
-	Parameters  []McpToolParameter // Properties of the tool
-	Output      []McpToolParameter // Output of the tool
+	Name        string              // Name of the tool
+	Title       string              // Human-readable title of the tool
+	Description string              // Description of the tool
+	Annotations *McpToolAnnotations // Hints about the behavior of the tool
+	Function    any                 // Function to be called
+	Parameters  []McpToolParameter  // Properties of the tool
+	Output      []McpToolParameter  // Output of the tool
+	Timeout     time.Duration       // Execution time limit of the tool. Zero uses the ToolTimeout of the server
+
+	MaxConcurrency  int          // Maximum number of concurrent tools/call executions of the tool. Zero means no limit
+	RateLimit       McpRateLimit // Rate limit of tools/call of the tool for each session
+	GlobalRateLimit McpRateLimit // Rate limit of tools/call of the tool across all sessions
+
+	seq uint64 // Registration sequence used to list tools in registration order
 }
 
 // String returns the name and parameters of the tool
@@ -75,6 +144,53 @@ func (t *McpTool) SetDescription(description string) *McpTool {
 	return t
 }
 
+// SetTitle sets the human-readable title of the tool
+func (t *McpTool) SetTitle(title string) *McpTool {
+	t.Title = title
+	return t
+}
+
+// SetTimeout sets the execution time limit of the tool
+func (t *McpTool) SetTimeout(timeout time.Duration) *McpTool {
+	t.Timeout = timeout
+	return t
+}
+
+// SetMaxConcurrency sets the maximum number of concurrent executions of the tool
+func (t *McpTool) SetMaxConcurrency(n int) *McpTool {
+	t.MaxConcurrency = n
+	return t
+}
+
+// SetRateLimit sets the rate limit of the tool for each session
+func (t *McpTool) SetRateLimit(limit McpRateLimit) *McpTool {
+	t.RateLimit = limit
+	return t
+}
+
+// SetGlobalRateLimit sets the rate limit of the tool across all sessions
+func (t *McpTool) SetGlobalRateLimit(limit McpRateLimit) *McpTool {
+	t.GlobalRateLimit = limit
+	return t
+}
+
+// SetAnnotations sets the behavior hints of the tool
+func (t *McpTool) SetAnnotations(annotations McpToolAnnotations) *McpTool {
+	t.Annotations = &annotations
+	return t
+}
+
+// McpToolHandler calls a tool with the decoded argument values, excluding context.Context, and returns its outputs.
+// A returned error is a failure to call the tool, or an McpError returned by the tool function which is sent to the client
+// as a JSON-RPC error. Other errors returned by the tool function, including errors of the library such as
+// ErrSamplingNotSupported and *McpClientError, are outputs of McpToolOutputTypeError.
+type McpToolHandler func(ctx context.Context, name string, args []any) ([]McpToolOutput, error)
+
+// McpToolInterceptor wraps tool calls, e.g. for tool-specific policies.
+// An interceptor can change the arguments before calling next, return a result without calling next (e.g. from a cache),
+// or change the outputs returned by next (e.g. to redact secrets).
+type McpToolInterceptor func(next McpToolHandler) McpToolHandler
+
 // McpToolParameter represents tool parameters with go-compatible data type.
 type McpToolParameter struct {
 	Name        string
@@ -98,6 +214,8 @@ const McpToolDataTypeBoolean McpToolDataType = "boolean"
 const McpToolDataTypeError McpToolDataType = "error"
 const McpToolDataTypeContext McpToolDataType = "context"
 const McpToolDataTypeImage McpToolDataType = "image"
+const McpToolDataTypeAudio McpToolDataType = "audio"
+const McpToolDataTypeContent McpToolDataType = "content" // McpToolOutput or []McpToolOutput returned by a tool
 
 type McpImageMimeType string
 
@@ -117,3 +235,22 @@ func (img *McpImage) GetImageBinary() ([]byte, error) {
 	}
 	return data, nil
 }
+
+type McpAudioMimeType string
+
+const McpAudioMimeTypeWAV McpAudioMimeType = "audio/wav"
+const McpAudioMimeTypeMPEG McpAudioMimeType = "audio/mpeg"
+const McpAudioMimeTypeOGG McpAudioMimeType = "audio/ogg"
+
+type McpAudio struct {
+	MimeType McpAudioMimeType `json:"mimeType"` // Mime type of the audio
+	Data     string           `json:"data"`     // Base64 encoded audio data
+}
+
+func (audio *McpAudio) GetAudioBinary() ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(audio.Data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
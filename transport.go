@@ -10,5 +10,30 @@ type McpTransportHandler interface {
 	// Note: All numeric values in the MCP request must use json.Number as their data type.
 	ProcessRequest(ctx context.Context, request any) (*McpRequest, error)
 
+	// ProcessResponse transforms an MCP response into a transport-layer response.
+	// A nil response, or a response with NoBody set, indicates that the request has no JSON-RPC response body.
 	ProcessResponse(ctx context.Context, response *McpResponse) (any, error)
 }
+
+// McpTerminateTransportHandler is implemented by transports which let the client terminate its session.
+// Transports which do not implement it never terminate sessions on request.
+type McpTerminateTransportHandler interface {
+	McpTransportHandler
+
+	// IsTerminateSessionRequest reports whether the transport-layer request asks to terminate the session
+	// (e.g. HTTP DELETE with Mcp-Session-Id header in streamable HTTP).
+	IsTerminateSessionRequest(ctx context.Context, request any) bool
+}
+
+// McpStreamTransportHandler is implemented by bidirectional transports which hold an open stream to the client
+// (e.g. SSE stream of streamable HTTP). It allows the server to send requests and notifications to the client.
+// Sessions are identified by McpSession.StableSessionID, which does not change when a session manager reissues the session ID.
+type McpStreamTransportHandler interface {
+	McpTransportHandler
+
+	// SendMessage sends a server-initiated JSON-RPC message to the client of the given session.
+	SendMessage(ctx context.Context, sessionID string, message any) error
+
+	// ConnectedSessions returns the stable IDs of sessions with an open stream to the client.
+	ConnectedSessions() []string
+}
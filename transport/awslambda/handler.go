@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -46,11 +48,6 @@ func (h *TransportHandler) ProcessRequest(ctx context.Context, request any) (*mc
 }
 
 func (h *TransportHandler) ProcessResponse(ctx context.Context, response *mcp.McpResponse) (any, error) {
-	sess, err := mcp.GetSessionFromContext(ctx)
-	if err != nil {
-		return nil, err
-	}
-
 	awsResponse := events.APIGatewayProxyResponse{
 		Body:       "",
 		StatusCode: http.StatusOK,
@@ -59,11 +56,31 @@ func (h *TransportHandler) ProcessResponse(ctx context.Context, response *mcp.Mc
 		},
 	}
 
-	// Set session ID in the response headers
-	if sess.SessionID != "" {
+	// Set session ID in the response headers.
+	// Session is not set when the request is rejected before the session is resolved.
+	if sess, err := mcp.GetSessionFromContext(ctx); err == nil && sess.SessionID != "" {
 		awsResponse.Headers["Mcp-Session-Id"] = sess.SessionID
 	}
 
+	// No response body, e.g. session termination
+	if response == nil {
+		return awsResponse, nil
+	}
+
+	// Use the status code hint of the response, e.g. 404 for unknown sessions
+	if code := response.TransportStatusCode(); code != 0 {
+		awsResponse.StatusCode = code
+	}
+	// Tell the client when to retry, e.g. after a rate limit. Retry-After is in whole seconds
+	if response.Error != nil && response.Error.RetryAfter > 0 {
+		awsResponse.Headers["Retry-After"] = strconv.FormatInt(int64(math.Ceil(response.Error.RetryAfter.Seconds())), 10)
+	}
+
+	// Notifications have no response body
+	if response.NoBody || awsResponse.StatusCode == http.StatusAccepted {
+		return awsResponse, nil
+	}
+
 	body, err := json.Marshal(response)
 	if err != nil {
 		return nil, fmt.Errorf("cannot encode response: %v", err)
@@ -72,3 +89,10 @@ func (h *TransportHandler) ProcessResponse(ctx context.Context, response *mcp.Mc
 
 	return awsResponse, nil
 }
+
+func (h *TransportHandler) IsTerminateSessionRequest(ctx context.Context, request any) bool {
+	if awsRequest, ok := request.(events.APIGatewayProxyRequest); ok {
+		return awsRequest.HTTPMethod == http.MethodDelete
+	}
+	return false
+}